@@ -0,0 +1,491 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	adminPageDefaultLimit = 20
+	adminPageMaxLimit     = 100
+	adminRecentLogins     = 20
+)
+
+var errAccountBanned = errors.New("hesap askıya alınmış")
+
+// checkUserActive, silinmiş veya askıya alınmış hesaplar için hata döndürür. Token veren
+// her yol bu kontrolden geçer.
+func checkUserActive(user *User) error {
+	if user.DeletedAt != nil {
+		return errAccountDeleted
+	}
+	if user.BannedAt != nil {
+		return errAccountBanned
+	}
+	return nil
+}
+
+// recordAudit, yöneticinin yaptığı işlemi denetim kaydına yazar. Denetim kayıtları hesap
+// silindiğinde de tutulur, bu yüzden userDataSources listesinde yer almaz.
+func (s *Server) recordAudit(ctx context.Context, r *http.Request, action string, targetID primitive.ObjectID, details bson.M) {
+	entry := AuditEntry{
+		Action:    action,
+		TargetID:  targetID,
+		Details:   details,
+		IP:        clientIP(r),
+		CreatedAt: time.Now(),
+	}
+	if claims := claimsFromRequest(r); claims != nil {
+		entry.ActorID, _ = primitive.ObjectIDFromHex(claims.Subject)
+		entry.ActorEmail = claims.Email
+	}
+	if err := s.audit.Insert(ctx, &entry); err != nil {
+		log.Printf("Denetim kaydı yazılamadı (%s): %v", action, err)
+	}
+}
+
+// parsePagination, page ve limit sorgu parametrelerini okur. Hata durumunda yanıtı kendisi yazar.
+func parsePagination(w http.ResponseWriter, r *http.Request) (page, limit int, ok bool) {
+	page, limit = 1, adminPageDefaultLimit
+	if v := r.URL.Query().Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, `{"error": "Geçersiz sayfa"}`, http.StatusBadRequest)
+			return 0, 0, false
+		}
+		page = n
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, `{"error": "Geçersiz limit"}`, http.StatusBadRequest)
+			return 0, 0, false
+		}
+		if n > adminPageMaxLimit {
+			n = adminPageMaxLimit
+		}
+		limit = n
+	}
+	return page, limit, true
+}
+
+// adminUserFromPath, URL'deki {id} ile kullanıcıyı getirir. Hata durumunda yanıtı kendisi yazar.
+func (s *Server) adminUserFromPath(ctx context.Context, w http.ResponseWriter, r *http.Request) (*User, bool) {
+	userID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Geçersiz kullanıcı ID"}`, http.StatusBadRequest)
+		return nil, false
+	}
+	user, err := s.users.FindByID(ctx, userID)
+	if err == errNotFound {
+		http.Error(w, `{"error": "Kullanıcı bulunamadı"}`, http.StatusNotFound)
+		return nil, false
+	} else if err != nil {
+		log.Printf("Veritabanı hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return nil, false
+	}
+	return user, true
+}
+
+func newAdminUserResponse(user *User) AdminUserResponse {
+	identities := user.Identities
+	if identities == nil {
+		identities = []Identity{}
+	}
+	return AdminUserResponse{
+		ID:                    user.ID.Hex(),
+		Ad:                    user.Ad,
+		Soyad:                 user.Soyad,
+		Email:                 user.Email,
+		Telefon:               user.Telefon,
+		Provider:              user.Provider,
+		Identities:            identities,
+		Roles:                 userRoles(user),
+		HasPassword:           user.Sifre != "",
+		TwoFactor:             user.TOTPEnabled,
+		PasswordResetRequired: user.PasswordResetRequired,
+		CreatedAt:             user.CreatedAt,
+		BannedAt:              user.BannedAt,
+		BanReason:             user.BanReason,
+		DeletedAt:             user.DeletedAt,
+		PurgeAt:               user.PurgeAt,
+	}
+}
+
+// adminListUsersHandler, kullanıcıları arar ve sayfalı olarak döndürür.
+// Filtreler: q (e-posta, ad veya soyad içinde), role, provider, status (active, banned, deleted).
+func (s *Server) adminListUsersHandler(w http.ResponseWriter, r *http.Request) {
+	page, limit, ok := parsePagination(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	filter := UserFilter{
+		Query:    query.Get("q"),
+		Role:     query.Get("role"),
+		Provider: query.Get("provider"),
+		Status:   query.Get("status"),
+	}
+	switch filter.Status {
+	case "", "active", "banned", "deleted":
+	default:
+		http.Error(w, `{"error": "Geçersiz durum filtresi"}`, http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	users, total, err := s.users.Search(ctx, filter, page, limit)
+	if err != nil {
+		log.Printf("Kullanıcı arama hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+
+	response := AdminUserListResponse{Users: make([]AdminUserResponse, 0, len(users)), Total: total, Page: page, Limit: limit}
+	for i := range users {
+		response.Users = append(response.Users, newAdminUserResponse(&users[i]))
+	}
+
+	s.recordAudit(ctx, r, "user.search", primitive.NilObjectID, bson.M{"query": query.Encode()})
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// adminGetUserHandler, kullanıcıyı aktif oturumları, passkey'leri, son girişleri ve kilit
+// durumuyla birlikte döndürür.
+func (s *Server) adminGetUserHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := s.adminUserFromPath(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	response := AdminUserDetailResponse{
+		User:         newAdminUserResponse(user),
+		Sessions:     []Session{},
+		Passkeys:     []WebAuthnCredential{},
+		RecentLogins: []LoginEvent{},
+	}
+
+	sessions, err := s.sessions.ListActive(ctx, user.ID, time.Now())
+	if err == nil {
+		response.Sessions = sessions
+		err = findAll(ctx, webauthnCredentialsCollection, bson.M{"userId": user.ID}, options.Find().SetSort(bson.M{"createdAt": 1}), &response.Passkeys)
+	}
+	if err == nil {
+		response.RecentLogins, err = s.loginEvents.ListByUser(ctx, user.ID, adminRecentLogins)
+	}
+	if err != nil {
+		log.Printf("Kullanıcı detay okuma hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+
+	lockout, err := s.lockouts.Find(ctx, accountLockoutKey(user.ID))
+	if err == nil {
+		status := &AdminLockoutStatus{Failures: lockout.Failures, LastFailureAt: lockout.LastFailureAt}
+		if lockout.LockedUntil.After(time.Now()) {
+			status.Locked = true
+			status.LockedUntil = &lockout.LockedUntil
+		}
+		response.Lockout = status
+	} else if err != errNotFound {
+		log.Printf("Kilit durumu okunamadı: %v", err)
+	}
+
+	s.recordAudit(ctx, r, "user.view", user.ID, nil)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// adminBanUserHandler, hesabı askıya alır ve tüm oturumlarını kapatır.
+func (s *Server) adminBanUserHandler(w http.ResponseWriter, r *http.Request) {
+	var req BanUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Geçersiz JSON formatı"}`, http.StatusBadRequest)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		http.Error(w, `{"error": "Askıya alma nedeni gerekli"}`, http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := s.adminUserFromPath(ctx, w, r)
+	if !ok {
+		return
+	}
+	if claims := claimsFromRequest(r); claims != nil && claims.Subject == user.ID.Hex() {
+		http.Error(w, `{"error": "Kendi hesabınızı askıya alamazsınız"}`, http.StatusBadRequest)
+		return
+	}
+
+	err := s.users.Ban(ctx, user.ID, req.Reason, time.Now())
+	if err != nil {
+		log.Printf("Hesap askıya alma hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+	// Verilmiş access token'lar da iptal listesine girer; yasak hemen etkili olur
+	if err := s.revokeAllUserTokens(ctx, user.ID); err != nil {
+		log.Printf("Oturum iptal hatası: %v", err)
+	}
+
+	s.recordAudit(ctx, r, "user.ban", user.ID, bson.M{"reason": req.Reason})
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MessageResponse{Message: "Hesap askıya alındı"})
+}
+
+// adminUnbanUserHandler, askıya alınmış hesabı yeniden açar.
+func (s *Server) adminUnbanUserHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := s.adminUserFromPath(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	err := s.users.Unban(ctx, user.ID)
+	if err != nil {
+		log.Printf("Hesap açma hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+
+	s.recordAudit(ctx, r, "user.unban", user.ID, nil)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MessageResponse{Message: "Hesabın askıya alınması kaldırıldı"})
+}
+
+// adminSetRolesHandler, kullanıcının rollerini ve doğrudan verilen izinlerini değiştirir.
+// İzinler token'a yazıldığı için bir izin kaldırıldığında kullanıcının oturumları kapatılır;
+// yeni izinler bir sonraki girişte veya token yenilemede geçerli olur.
+func (s *Server) adminSetRolesHandler(w http.ResponseWriter, r *http.Request) {
+	var req SetRolesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Geçersiz JSON formatı"}`, http.StatusBadRequest)
+		return
+	}
+	roles := normalizeList(req.Roles)
+	permissions := normalizeList(req.Permissions)
+	var errs []FieldError
+	for _, role := range roles {
+		if _, ok := rolePermissions[role]; !ok {
+			errs = append(errs, FieldError{Field: "roles", Code: "unknown_role", Message: "Bilinmeyen rol: " + role})
+		}
+	}
+	for _, perm := range permissions {
+		if !validPermission(perm) {
+			errs = append(errs, FieldError{Field: "permissions", Code: "unknown_permission", Message: "Bilinmeyen izin: " + perm})
+		}
+	}
+	if len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := s.adminUserFromPath(ctx, w, r)
+	if !ok {
+		return
+	}
+	if claims := claimsFromRequest(r); claims != nil && claims.Subject == user.ID.Hex() && !containsString(roles, RoleAdmin) {
+		http.Error(w, `{"error": "Kendi yönetici rolünüzü kaldıramazsınız"}`, http.StatusBadRequest)
+		return
+	}
+
+	before := effectivePermissions(user)
+	previousRoles, previousPermissions := userRoles(user), user.Permissions
+	if err := s.users.SetRoles(ctx, user.ID, roles, permissions); err != nil {
+		log.Printf("Rol güncelleme hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+
+	user.Roles, user.Permissions = roles, permissions
+	after := effectivePermissions(user)
+	for _, perm := range before {
+		if !containsString(after, perm) {
+			// Eski token'lar kaldırılan izni taşımaya devam etmesin
+			if err := s.revokeAllUserTokens(ctx, user.ID); err != nil {
+				log.Printf("Oturum iptal hatası: %v", err)
+			}
+			break
+		}
+	}
+
+	s.recordAudit(ctx, r, "user.roles", user.ID, bson.M{
+		"previousRoles":       previousRoles,
+		"previousPermissions": previousPermissions,
+		"roles":               userRoles(user),
+		"permissions":         permissions,
+	})
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newAdminUserResponse(user))
+}
+
+// adminForcePasswordResetHandler, kullanıcının şifreyle girişini şifresini sıfırlayana kadar
+// engeller, tüm oturumlarını kapatır ve sıfırlama kodunu e-postayla gönderir.
+func (s *Server) adminForcePasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := s.adminUserFromPath(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	err := s.users.RequirePasswordReset(ctx, user.ID)
+	if err != nil {
+		log.Printf("Şifre sıfırlama zorlama hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+	if err := s.revokeAllUserTokens(ctx, user.ID); err != nil {
+		log.Printf("Oturum iptal hatası: %v", err)
+	}
+
+	codeSent := true
+	code, err := s.issueVerificationCode(ctx, CodePurposeReset, user.Email, 10*time.Minute)
+	if _, ok := err.(*CodeCooldownError); ok {
+		// Kullanıcıya az önce bir kod gitti; o kod hâlâ geçerli
+		codeSent = false
+	} else if err != nil {
+		log.Printf("Doğrulama kodu kaydetme hatası: %v", err)
+		http.Error(w, `{"error": "Sıfırlama kodu oluşturulamadı"}`, http.StatusInternalServerError)
+		return
+	} else {
+		go func() {
+			body := fmt.Sprintf("Merhaba %s,\n\nGüvenliğiniz için hesabınızın şifresinin yenilenmesi gerekiyor ve tüm cihazlardan çıkış yapıldı.\n\nŞifre sıfırlama kodunuz: %s\n\nBu kod 10 dakika geçerlidir. Süre dolarsa uygulamadaki \"Şifremi unuttum\" adımıyla yeni kod isteyebilirsiniz.", user.Ad, code)
+			if err := s.sendEmail(user.Email, "Şifrenizi yenilemeniz gerekiyor", body); err != nil {
+				log.Printf("E-posta gönderim hatası (asenkron): %v", err)
+			}
+		}()
+	}
+
+	s.recordAudit(ctx, r, "user.force_password_reset", user.ID, bson.M{"codeSent": codeSent})
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MessageResponse{Message: "Kullanıcının şifresini yenilemesi zorunlu kılındı"})
+}
+
+// adminResendVerificationHandler, kaydını tamamlayamamış bir e-posta adresine yeni doğrulama
+// kodu gönderir.
+func (s *Server) adminResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	var req SendCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Geçersiz JSON formatı"}`, http.StatusBadRequest)
+		return
+	}
+	req.Email = normalizeEmail(req.Email)
+	if req.Email == "" {
+		http.Error(w, `{"error": "E-posta gerekli"}`, http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	exists, err := s.users.EmailExists(ctx, req.Email)
+	if err != nil {
+		log.Printf("Veritabanı hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+	if exists {
+		http.Error(w, `{"error": "Bu e-posta ile kayıtlı bir hesap zaten var"}`, http.StatusConflict)
+		return
+	}
+
+	code, err := s.issueVerificationCode(ctx, CodePurposeRegister, req.Email, 3*time.Minute)
+	if cooldown, ok := err.(*CodeCooldownError); ok {
+		writeRetryAfter(w, cooldown.RetryAfter)
+		http.Error(w, `{"error": "Yeni kod istemeden önce lütfen bekleyin"}`, http.StatusTooManyRequests)
+		return
+	} else if err != nil {
+		log.Printf("Doğrulama kodu kaydetme hatası: %v", err)
+		http.Error(w, `{"error": "Doğrulama kodu gönderilemedi"}`, http.StatusInternalServerError)
+		return
+	}
+
+	go func() {
+		mailBody := fmt.Sprintf("Merhaba,\n\nDoğrulama kodunuz: %s\n\nBu kod 3 dakika içinde geçerliliğini yitirecektir.\n\nİyi günler.", code)
+		if err := s.sendEmail(req.Email, "Hesap Doğrulama Kodunuz", mailBody); err != nil {
+			log.Printf("E-posta gönderim hatası (asenkron): %v", err)
+		}
+	}()
+
+	s.recordAudit(ctx, r, "verification.resend", primitive.NilObjectID, bson.M{"email": req.Email})
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MessageResponse{Message: "Doğrulama kodu gönderildi"})
+}
+
+// adminAuditLogHandler, denetim kayıtlarını yeniden eskiye sayfalı olarak döndürür.
+// Filtreler: actor ve target (kullanıcı ID), action.
+func (s *Server) adminAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	page, limit, ok := parsePagination(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	filter := AuditFilter{Action: query.Get("action")}
+	for param, field := range map[string]*primitive.ObjectID{"actor": &filter.ActorID, "target": &filter.TargetID} {
+		if v := query.Get(param); v != "" {
+			id, err := primitive.ObjectIDFromHex(v)
+			if err != nil {
+				http.Error(w, `{"error": "Geçersiz kullanıcı ID"}`, http.StatusBadRequest)
+				return
+			}
+			*field = id
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	entries, total, err := s.audit.List(ctx, filter, page, limit)
+	if err != nil {
+		log.Printf("Denetim kaydı okuma hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"entries": entries,
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+	})
+}
@@ -3,33 +3,22 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"math/rand"
 	"net/http"
-	"net/smtp"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 	"golang.org/x/crypto/bcrypt"
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 )
 
-var jwtKey = []byte(os.Getenv("JWT_SECRET_KEY"))
-var googleOAuthConfig *oauth2.Config
+var errTokenRevoked = errors.New("token iptal edilmiş")
 
 // --- Yardımcı Fonksiyonlar ---
 
-func generateVerificationCode() string {
-	code := fmt.Sprintf("%06d", rand.Intn(999999))
-	return code
-}
-
 func hashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 12)
 	return string(bytes), err
@@ -40,59 +29,102 @@ func checkPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
-// E-posta gönderme fonksiyonu
-func sendEmail(to, subject, body string) error {
-	smtpHost := os.Getenv("SMTP_HOST")
-	smtpPort := os.Getenv("SMTP_PORT")
-	smtpUsername := os.Getenv("SMTP_USER")
-	smtpPassword := os.Getenv("SMTP_PASSWORD")
+// tokenIssuer, token'lara yazılan ve doğrulamada beklenen "iss" değeridir.
+func tokenIssuer() string {
+	if issuer := os.Getenv("JWT_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "eventra"
+}
 
-	msg := []byte("To: " + to + "\r\n" +
-		"Subject: " + subject + "\r\n" +
-		"\r\n" +
-		body + "\r\n")
+func createToken(user *User, sessionID string) (string, string, error) {
+	jti, err := generateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
 
-	auth := smtp.PlainAuth("", smtpUsername, smtpPassword, smtpHost)
-	addr := smtpHost + ":" + smtpPort
+	now := time.Now()
+	expirationTime := now.Add(accessTokenTTL) // Kısa ömürlü; refresh token ile yenilenir
+	claims := &Claims{
+		Email:       user.Email,
+		SessionID:   sessionID,
+		Roles:       userRoles(user),
+		Permissions: effectivePermissions(user),
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			Issuer:    tokenIssuer(),
+			Subject:   user.ID.Hex(),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expirationTime.Unix(),
+		},
+	}
 
-	err := smtp.SendMail(addr, auth, smtpUsername, []string{to}, msg)
+	tokenString, err := jwtKeys.sign(claims)
 	if err != nil {
-		return err
+		return "", "", err
 	}
-	return nil
+	return tokenString, jti, nil
 }
 
-func init() {
-	// Google OAuth2 yapılandırması
-	googleOAuthConfig = &oauth2.Config{
-		ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
-		ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
-		RedirectURL:  os.Getenv("GOOGLE_REDIRECT_URL"),
-		Scopes:       []string{"https://www.googleapis.com/auth/userinfo.email", "https://www.googleapis.com/auth/userinfo.profile"},
-		Endpoint:     google.Endpoint,
+// createPurposeToken, yalnızca belirli bir işlem için geçerli kısa ömürlü bir token üretir
+// (ör. hesap bağlama bileti). Bu token'lar access token olarak kabul edilmez.
+func createPurposeToken(user *User, purpose string, ttl time.Duration) (string, error) {
+	jti, err := generateOpaqueToken()
+	if err != nil {
+		return "", err
 	}
-}
 
-func createToken(email string) (string, error) {
-	expirationTime := time.Now().Add(24 * 7 * time.Hour) // Token 7 gün geçerli olacak
+	now := time.Now()
 	claims := &Claims{
-		Email: email,
+		Email:   user.Email,
+		Purpose: purpose,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(),
+			Id:        jti,
+			Issuer:    tokenIssuer(),
+			Subject:   user.ID.Hex(),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
 		},
 	}
+	return jwtKeys.sign(claims)
+}
+
+// parseToken, access token'ın imzasını ve süresini doğrular, ardından iptal listesini kontrol eder.
+func (s *Server) parseToken(tokenString string) (*Claims, error) {
+	return s.parsePurposeToken(tokenString, "")
+}
+
+// parsePurposeToken, token'ı doğrular ve amacının beklenenle aynı olduğunu kontrol eder.
+// Boş amaç normal access token demektir.
+func (s *Server) parsePurposeToken(tokenString, purpose string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, jwtKeys.keyFunc)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid || claims.Id == "" || !claims.VerifyIssuer(tokenIssuer(), true) {
+		return nil, jwt.ErrSignatureInvalid
+	}
+	if claims.Purpose != purpose {
+		return nil, jwt.ErrSignatureInvalid
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtKey)
+	revoked, err := s.isTokenRevoked(ctx, claims.Id)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if revoked {
+		return nil, errTokenRevoked
 	}
-	return tokenString, nil
+	return claims, nil
 }
 
 // --- Handler Fonksiyonları ---
 
-func sendCodeHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) sendCodeHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
@@ -111,32 +143,25 @@ func sendCodeHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Geçersiz istek gövdesi", http.StatusBadRequest)
 		return
 	}
+	req.Email = normalizeEmail(req.Email)
 
-	var existingUser User
-	err := usersCollection.FindOne(context.Background(), bson.M{"email": req.Email}).Decode(&existingUser)
-	if err == nil {
-		http.Error(w, "Bu email zaten kayıtlı", http.StatusConflict)
-		return
-	}
-	if err != mongo.ErrNoDocuments {
+	exists, err := s.users.EmailExists(context.Background(), req.Email)
+	if err != nil {
 		log.Printf("MongoDB sorgu hatası: %v", err)
 		http.Error(w, "Sunucu hatası", http.StatusInternalServerError)
 		return
 	}
+	if exists {
+		http.Error(w, "Bu email zaten kayıtlı", http.StatusConflict)
+		return
+	}
 
-	code := generateVerificationCode()
-	expiresAt := time.Now().Add(3 * time.Minute)
-
-	_, err = verificationCollection.UpdateOne(
-		context.Background(),
-		bson.M{"email": req.Email},
-		bson.M{"$set": bson.M{
-			"code":      code,
-			"expiresAt": expiresAt,
-		}},
-		options.Update().SetUpsert(true),
-	)
-	if err != nil {
+	code, err := s.issueVerificationCode(context.Background(), CodePurposeRegister, req.Email, 3*time.Minute)
+	if cooldown, ok := err.(*CodeCooldownError); ok {
+		writeRetryAfter(w, cooldown.RetryAfter)
+		http.Error(w, "Yeni kod istemeden önce lütfen bekleyin", http.StatusTooManyRequests)
+		return
+	} else if err != nil {
 		log.Printf("Doğrulama kodu kaydetme hatası: %v", err)
 		http.Error(w, "Doğrulama kodu gönderilemedi", http.StatusInternalServerError)
 		return
@@ -144,8 +169,7 @@ func sendCodeHandler(w http.ResponseWriter, r *http.Request) {
 
 	go func() {
 		mailBody := fmt.Sprintf("Merhaba,\n\nDoğrulama kodunuz: %s\n\nBu kod 3 dakika içinde geçerliliğini yitirecektir.\n\nİyi günler.", code)
-		err = sendEmail(req.Email, "Hesap Doğrulama Kodunuz", mailBody)
-		if err != nil {
+		if err := s.sendEmail(req.Email, "Hesap Doğrulama Kodunuz", mailBody); err != nil {
 			log.Printf("E-posta gönderim hatası (asenkron): %v", err)
 		} else {
 			log.Printf("Doğrulama kodu başarıyla gönderildi: %s", req.Email)
@@ -157,7 +181,7 @@ func sendCodeHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(MessageResponse{Message: "Doğrulama kodu e-mail adresinize başarıyla gönderildi."})
 }
 
-func loginHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) loginHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
@@ -176,27 +200,90 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Geçersiz istek gövdesi", http.StatusBadRequest)
 		return
 	}
+	req.Email = normalizeEmail(req.Email)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
 	// Kullanıcıyı veritabanında ara
-	var user User
-	err := usersCollection.FindOne(context.Background(), bson.M{"email": req.Email, "provider": "email"}).Decode(&user)
-	if err == mongo.ErrNoDocuments {
-		http.Error(w, "Kullanıcı bulunamadı veya yanlış kimlik doğrulama yöntemi", http.StatusUnauthorized)
-		return
-	} else if err != nil {
+	user, err := s.users.FindByEmail(ctx, req.Email)
+	if err != nil && err != errNotFound {
 		log.Printf("Veritabanı hatası: %v", err)
 		http.Error(w, "Sunucu hatası", http.StatusInternalServerError)
 		return
 	}
 
+	// Deneme şifre kontrolünden önce ayrılır; hesap veya IP kilitliyse şifre hiç denenmez
+	attempt, remaining, lockErr := s.beginLoginAttempt(ctx, r, user)
+	if lockErr != nil {
+		log.Printf("Kilit durumu okunamadı: %v", lockErr)
+		http.Error(w, "Sunucu hatası", http.StatusInternalServerError)
+		return
+	}
+	if remaining > 0 {
+		if err == nil {
+			s.recordLoginEvent(r, user, req.Email, "password", false, "locked")
+		}
+		writeRetryAfter(w, remaining)
+		http.Error(w, "Çok fazla hatalı deneme yapıldı, lütfen daha sonra tekrar deneyin", http.StatusTooManyRequests)
+		return
+	}
+
+	if err == errNotFound || user.Sifre == "" {
+		// Yalnızca sosyal girişle kullanılan hesapların şifresi yoktur
+		if err == nil {
+			s.recordLoginEvent(r, user, req.Email, "password", false, "no_password")
+		} else {
+			s.recordLoginEvent(r, nil, req.Email, "password", false, "unknown_user")
+		}
+		s.handleLoginFailure(ctx, r, nil, attempt)
+		http.Error(w, "Kullanıcı bulunamadı veya yanlış kimlik doğrulama yöntemi", http.StatusUnauthorized)
+		return
+	}
+
 	// Şifreyi kontrol et
 	if !checkPasswordHash(req.Sifre, user.Sifre) {
+		s.recordLoginEvent(r, user, req.Email, "password", false, "wrong_password")
+		s.handleLoginFailure(ctx, r, user, attempt)
 		http.Error(w, "Hatalı şifre", http.StatusUnauthorized)
 		return
 	}
+	attempt.succeeded(ctx, user)
+	switch checkUserActive(user) {
+	case errAccountDeleted:
+		s.recordLoginEvent(r, user, req.Email, "password", false, "deleted")
+		http.Error(w, "Bu hesap silinme sürecinde", http.StatusForbidden)
+		return
+	case errAccountBanned:
+		s.recordLoginEvent(r, user, req.Email, "password", false, "banned")
+		http.Error(w, "Hesabınız askıya alınmış", http.StatusForbidden)
+		return
+	}
+	if user.PasswordResetRequired {
+		http.Error(w, "Devam etmeden önce şifrenizi sıfırlamanız gerekiyor", http.StatusForbidden)
+		return
+	}
+
+	// 2FA açıksa token yerine kısa ömürlü bir challenge token verilir; giriş /login/2fa ile tamamlanır
+	if user.TOTPEnabled {
+		challenge, err := createPurposeToken(user, "2fa", twoFactorChallengeTTL)
+		if err != nil {
+			log.Printf("Token oluşturma hatası: %v", err)
+			http.Error(w, "Token oluşturulamadı", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(TwoFactorLoginResponse{
+			Status:         "2fa_required",
+			Message:        "İki adımlı doğrulama kodu gerekli",
+			ChallengeToken: challenge,
+		})
+		return
+	}
 
-	// Token oluştur ve yanıtla birlikte gönder
-	token, err := createToken(user.Email)
+	// Token ikilisini oluştur ve yanıtla birlikte gönder
+	pair, err := s.issueLoginTokens(ctx, user, r, "password")
 	if err != nil {
 		log.Printf("Token oluşturma hatası: %v", err)
 		http.Error(w, "Token oluşturulamadı", http.StatusInternalServerError)
@@ -206,14 +293,16 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 	// Başarılı giriş
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"status":  "success",
-		"message": "Giriş başarılı",
-		"token":   token,
+	json.NewEncoder(w).Encode(TokenResponse{
+		Status:       "success",
+		Message:      "Giriş başarılı",
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
 	})
 }
 
-func registerHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) registerHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
@@ -231,17 +320,26 @@ func registerHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Geçersiz istek gövdesi", http.StatusBadRequest)
 		return
 	}
+	req.Email = normalizeEmail(req.Email)
 
-	var verCode VerificationCode
-	err := verificationCollection.FindOne(context.Background(), bson.M{"email": req.Email}).Decode(&verCode)
-	if err != nil {
-		http.Error(w, "Doğrulama kodu geçersiz veya süresi dolmuş", http.StatusUnauthorized)
+	// Şifre, doğrulama kodu harcanmadan önce denetlenir
+	if errs := passwordPolicy.Validate("sifre", req.Sifre, PasswordContext{Email: req.Email, Ad: req.Ad, Soyad: req.Soyad}); len(errs) > 0 {
+		writeValidationErrors(w, errs)
 		return
 	}
 
-	if verCode.Code != req.VerificationCode || time.Now().After(verCode.ExpiresAt) {
+	// Kod tüketilir; aynı kodla gelen eşzamanlı ikinci kayıt isteği başarısız olur
+	err := s.consumeVerificationCode(context.Background(), CodePurposeRegister, req.Email, req.VerificationCode)
+	if err == errCodeTooManyAttempts {
+		http.Error(w, "Çok fazla hatalı deneme, lütfen yeni kod isteyin", http.StatusTooManyRequests)
+		return
+	} else if err == errCodeInvalid {
 		http.Error(w, "Doğrulama kodu geçersiz veya süresi dolmuş", http.StatusUnauthorized)
 		return
+	} else if err != nil {
+		log.Printf("Doğrulama kodu kontrol hatası: %v", err)
+		http.Error(w, "Sunucu hatası", http.StatusInternalServerError)
+		return
 	}
 
 	hashedPassword, err := hashPassword(req.Sifre)
@@ -262,90 +360,23 @@ func registerHandler(w http.ResponseWriter, r *http.Request) {
 		CreatedAt:   time.Now(),
 	}
 
-	_, err = usersCollection.InsertOne(context.Background(), newUser)
-	if err != nil {
+	err = s.users.Create(context.Background(), &newUser)
+	if err == errEmailTaken {
+		http.Error(w, "Bu email zaten kayıtlı", http.StatusConflict)
+		return
+	} else if err != nil {
 		log.Printf("Kullanıcı kaydetme hatası: %v", err)
 		http.Error(w, "Kayıt işlemi başarısız oldu", http.StatusInternalServerError)
 		return
 	}
 
-	_, err = verificationCollection.DeleteOne(context.Background(), bson.M{"email": req.Email})
-	if err != nil {
-		log.Printf("Doğrulama kodu silme hatası: %v", err)
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(MessageResponse{Message: "Kayıt işlemi başarıyla tamamlandı."})
 }
 
-func googleLoginHandler(w http.ResponseWriter, r *http.Request) {
-	url := googleOAuthConfig.AuthCodeURL("random-state", oauth2.AccessTypeOffline, oauth2.ApprovalForce)
-	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
-}
-
-func googleCallbackHandler(w http.ResponseWriter, r *http.Request) {
-	state := r.FormValue("state")
-	if state != "random-state" {
-		http.Error(w, "State geçersiz", http.StatusBadRequest)
-		return
-	}
-
-	code := r.FormValue("code")
-	token, err := googleOAuthConfig.Exchange(context.Background(), code)
-	if err != nil {
-		http.Error(w, "Token alınamadı", http.StatusInternalServerError)
-		return
-	}
-
-	client := googleOAuthConfig.Client(context.Background(), token)
-	resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
-	if err != nil {
-		http.Error(w, "Kullanıcı bilgileri alınamadı", http.StatusInternalServerError)
-		return
-	}
-	defer resp.Body.Close()
-
-	var googleUser GoogleUser
-	if err := json.NewDecoder(resp.Body).Decode(&googleUser); err != nil {
-		http.Error(w, "Kullanıcı bilgileri çözülemedi", http.StatusInternalServerError)
-		return
-	}
-
-	var user User
-	err = usersCollection.FindOne(context.Background(), bson.M{"email": googleUser.Email, "provider": "google"}).Decode(&user)
-	if err == mongo.ErrNoDocuments {
-		newUser := User{
-			Ad:        googleUser.GivenName,
-			Soyad:     googleUser.FamilyName,
-			Email:     googleUser.Email,
-			Provider:  "google",
-			SocialID:  googleUser.Email,
-			CreatedAt: time.Now(),
-		}
-		if _, err = usersCollection.InsertOne(context.Background(), newUser); err != nil {
-			http.Error(w, "Kayıt başarısız", http.StatusInternalServerError)
-			return
-		}
-	} else if err != nil {
-		http.Error(w, "Sunucu hatası", http.StatusInternalServerError)
-		return
-	}
-
-	jwtToken, err := createToken(googleUser.Email)
-	if err != nil {
-		http.Error(w, "Token oluşturma başarısız", http.StatusInternalServerError)
-		return
-	}
-
-	// Derin bağlantı ile uygulamaya dön (görünür HTML olmadan)
-	redirectURL := fmt.Sprintf("etkinlikuygulamasi://login/success?token=%s&type=google", jwtToken)
-	w.Header().Set("X-Content-Type-Options", "nosniff")
-	http.Redirect(w, r, redirectURL, http.StatusFound)
-}
-
 // verifyTokenHandler, gönderilen token'ı doğrular ve geçerliyse kullanıcı bilgilerini döndürür
-func verifyTokenHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) verifyTokenHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
@@ -355,28 +386,28 @@ func verifyTokenHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tokenString := r.Header.Get("Authorization")
+	tokenString := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
 	if tokenString == "" {
 		http.Error(w, "Yetkilendirme token'ı eksik", http.StatusUnauthorized)
 		return
 	}
 
-	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
-	})
-
-	if err != nil || !token.Valid {
+	claims, err := s.parseToken(tokenString)
+	if err != nil {
 		http.Error(w, "Geçersiz token", http.StatusUnauthorized)
 		return
 	}
 
-	var user User
-	err = usersCollection.FindOne(context.Background(), bson.M{"email": claims.Email}).Decode(&user)
+	// Token'daki e-posta sonradan değişmiş olabilir; kullanıcı ID'siyle (sub) bulunur
+	user, err := s.getUserByClaims(claims)
 	if err != nil {
 		http.Error(w, "Kullanıcı bulunamadı", http.StatusUnauthorized)
 		return
 	}
+	if err := checkUserActive(user); err != nil {
+		http.Error(w, "Hesap kullanıma kapalı", http.StatusUnauthorized)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -386,16 +417,17 @@ func verifyTokenHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // Şifre sıfırlama kodu gönderme handler'ı
-func sendPasswordResetCodeHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) sendPasswordResetCodeHandler(w http.ResponseWriter, r *http.Request) {
 	var req SendCodeRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Geçersiz istek gövdesi", http.StatusBadRequest)
 		return
 	}
+	req.Email = normalizeEmail(req.Email)
 
-	var user User
-	err := usersCollection.FindOne(context.Background(), bson.M{"email": req.Email, "provider": "email"}).Decode(&user)
-	if err == mongo.ErrNoDocuments {
+	// Sosyal girişle oluşturulmuş hesaplar da bu akışla şifre belirleyebilir
+	_, err := s.users.FindByEmail(context.Background(), req.Email)
+	if err == errNotFound {
 		http.Error(w, "Kullanıcı bulunamadı", http.StatusNotFound)
 		return
 	} else if err != nil {
@@ -404,29 +436,21 @@ func sendPasswordResetCodeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	verificationCode := generateVerificationCode()
-	err = sendEmail(req.Email, "Şifre Sıfırlama Kodunuz", fmt.Sprintf("Şifre sıfırlama kodunuz: %s", verificationCode))
-	if err != nil {
-		log.Printf("E-posta gönderme hatası: %v", err)
-		http.Error(w, "E-posta gönderme başarısız", http.StatusInternalServerError)
+	verificationCode, err := s.issueVerificationCode(context.Background(), CodePurposeReset, req.Email, 10*time.Minute) // 10 dakika geçerlilik süresi
+	if cooldown, ok := err.(*CodeCooldownError); ok {
+		writeRetryAfter(w, cooldown.RetryAfter)
+		http.Error(w, "Yeni kod istemeden önce lütfen bekleyin", http.StatusTooManyRequests)
+		return
+	} else if err != nil {
+		log.Printf("Doğrulama kodu kaydetme hatası: %v", err)
+		http.Error(w, "Sunucu hatası", http.StatusInternalServerError)
 		return
 	}
 
-	_, err = verificationCollection.UpdateOne(
-		context.Background(),
-		bson.M{"email": req.Email},
-		bson.M{
-			"$set": bson.M{
-				"email":     req.Email,
-				"code":      verificationCode,
-				"expiresAt": time.Now().Add(10 * time.Minute), // 10 dakika geçerlilik süresi
-			},
-		},
-		options.Update().SetUpsert(true),
-	)
+	err = s.sendEmail(req.Email, "Şifre Sıfırlama Kodunuz", fmt.Sprintf("Şifre sıfırlama kodunuz: %s", verificationCode))
 	if err != nil {
-		log.Printf("Doğrulama kodu kaydetme hatası: %v", err)
-		http.Error(w, "Sunucu hatası", http.StatusInternalServerError)
+		log.Printf("E-posta gönderme hatası: %v", err)
+		http.Error(w, "E-posta gönderme başarısız", http.StatusInternalServerError)
 		return
 	}
 
@@ -435,16 +459,34 @@ func sendPasswordResetCodeHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // Şifre sıfırlama handler'ı
-func resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
 	var req ResetPasswordRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Geçersiz istek gövdesi", http.StatusBadRequest)
 		return
 	}
+	req.Email = normalizeEmail(req.Email)
 
-	var storedCode VerificationCode
-	err := verificationCollection.FindOne(context.Background(), bson.M{"email": req.Email, "code": req.Code}).Decode(&storedCode)
-	if err == mongo.ErrNoDocuments || time.Now().After(storedCode.ExpiresAt) {
+	// Kişisel bilgi kontrolü için kullanıcı okunur; bulunamazsa kod kontrolü zaten başarısız olur
+	info := PasswordContext{Email: req.Email}
+	user, err := s.users.FindByEmail(context.Background(), req.Email)
+	if err == nil {
+		info.Ad, info.Soyad = user.Ad, user.Soyad
+	} else if err != errNotFound {
+		log.Printf("Veritabanı hatası: %v", err)
+		http.Error(w, "Sunucu hatası", http.StatusInternalServerError)
+		return
+	}
+	if errs := passwordPolicy.Validate("newPassword", req.NewPassword, info); len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	err = s.consumeVerificationCode(context.Background(), CodePurposeReset, req.Email, req.Code)
+	if err == errCodeTooManyAttempts {
+		http.Error(w, "Çok fazla hatalı deneme, lütfen yeni kod isteyin", http.StatusTooManyRequests)
+		return
+	} else if err == errCodeInvalid || user == nil {
 		http.Error(w, "Geçersiz veya süresi dolmuş kod", http.StatusUnauthorized)
 		return
 	} else if err != nil {
@@ -459,22 +501,13 @@ func resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = usersCollection.UpdateOne(
-		context.Background(),
-		bson.M{"email": req.Email},
-		bson.M{"$set": bson.M{"sifre": hashedPassword}},
-	)
+	err = s.users.SetPassword(context.Background(), user.ID, hashedPassword)
 	if err != nil {
 		log.Printf("Şifre güncelleme hatası: %v", err)
 		http.Error(w, "Şifre güncellenemedi", http.StatusInternalServerError)
 		return
 	}
 
-	_, err = verificationCollection.DeleteOne(context.Background(), bson.M{"email": req.Email})
-	if err != nil {
-		log.Printf("Doğrulama kodu silme hatası: %v", err)
-	}
-
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(MessageResponse{Message: "Şifreniz başarıyla sıfırlandı."})
 }
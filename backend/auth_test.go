@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newTestServer, bellek içi store'lar ve MemoryMailer ile bir Server kurar.
+func newTestServer() (*Server, *MemoryMailer) {
+	mailer := NewMemoryMailer("test@eventra.local")
+	return NewServer(NewMemoryStores(), mailer), mailer
+}
+
+func postJSON(t *testing.T, handler http.HandlerFunc, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+var mailedCodePattern = regexp.MustCompile(`\b\d{6}\b`)
+
+// waitForCode, adrese gönderilen son e-postadaki 6 haneli kodu döndürür. Bazı handler'lar
+// e-postayı arka planda gönderdiği için kısa bir süre beklenir.
+func waitForCode(t *testing.T, mailer *MemoryMailer, to string) string {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if msg, ok := mailer.Last(to); ok {
+			code := mailedCodePattern.FindString(msg.Body)
+			if code == "" {
+				t.Fatalf("e-postada kod yok: %q", msg.Body)
+			}
+			return code
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%s adresine e-posta gönderilmedi", to)
+	return ""
+}
+
+func TestRegisterWithEmailedCode(t *testing.T) {
+	s, mailer := newTestServer()
+	const email = "ayse@example.com"
+
+	if rec := postJSON(t, s.sendCodeHandler, SendCodeRequest{Email: email}); rec.Code != http.StatusOK {
+		t.Fatalf("sendCode: %d %s", rec.Code, rec.Body)
+	}
+	code := waitForCode(t, mailer, email)
+
+	register := RegisterRequest{Ad: "Ayşe", Soyad: "Yılmaz", Email: email, Sifre: "Gizli-Parola-42", VerificationCode: code}
+	if rec := postJSON(t, s.registerHandler, register); rec.Code != http.StatusCreated {
+		t.Fatalf("register: %d %s", rec.Code, rec.Body)
+	}
+
+	user, err := s.users.FindByEmail(context.Background(), email)
+	if err != nil {
+		t.Fatalf("kullanıcı oluşturulmadı: %v", err)
+	}
+	if !checkPasswordHash(register.Sifre, user.Sifre) {
+		t.Error("şifre özeti eşleşmiyor")
+	}
+
+	// Kod tüketildiği için ikinci kayıt aynı kodla yapılamaz
+	if rec := postJSON(t, s.registerHandler, register); rec.Code != http.StatusUnauthorized {
+		t.Errorf("aynı kodla ikinci kayıt: %d, beklenen 401", rec.Code)
+	}
+}
+
+func TestRegisterNormalizesEmail(t *testing.T) {
+	s, mailer := newTestServer()
+
+	if rec := postJSON(t, s.sendCodeHandler, SendCodeRequest{Email: " Burak@Example.COM "}); rec.Code != http.StatusOK {
+		t.Fatalf("sendCode: %d %s", rec.Code, rec.Body)
+	}
+	code := waitForCode(t, mailer, "burak@example.com")
+
+	register := RegisterRequest{Ad: "Burak", Soyad: "Öztürk", Email: "BURAK@example.com", Sifre: "Gizli-Parola-42", VerificationCode: code}
+	if rec := postJSON(t, s.registerHandler, register); rec.Code != http.StatusCreated {
+		t.Fatalf("register: %d %s", rec.Code, rec.Body)
+	}
+	if _, err := s.users.FindByEmail(context.Background(), "burak@example.com"); err != nil {
+		t.Fatalf("e-posta küçük harfle kaydedilmedi: %v", err)
+	}
+
+	// Farklı yazımla aynı adrese ikinci kod istenemez
+	if rec := postJSON(t, s.sendCodeHandler, SendCodeRequest{Email: "Burak@example.com"}); rec.Code != http.StatusConflict {
+		t.Errorf("kayıtlı adres farklı yazımla: %d, beklenen 409", rec.Code)
+	}
+}
+
+func TestRegisterRejectsWrongCodeAndCooldown(t *testing.T) {
+	s, mailer := newTestServer()
+	const email = "mehmet@example.com"
+
+	if rec := postJSON(t, s.sendCodeHandler, SendCodeRequest{Email: email}); rec.Code != http.StatusOK {
+		t.Fatalf("sendCode: %d %s", rec.Code, rec.Body)
+	}
+	code := waitForCode(t, mailer, email)
+
+	if rec := postJSON(t, s.sendCodeHandler, SendCodeRequest{Email: email}); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("bekleme süresinde yeni kod: %d, beklenen 429", rec.Code)
+	}
+
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	register := RegisterRequest{Ad: "Mehmet", Soyad: "Kaya", Email: email, Sifre: "Gizli-Parola-42", VerificationCode: wrong}
+	if rec := postJSON(t, s.registerHandler, register); rec.Code != http.StatusUnauthorized {
+		t.Errorf("yanlış kod: %d, beklenen 401", rec.Code)
+	}
+	if _, err := s.users.FindByEmail(context.Background(), email); err != errNotFound {
+		t.Errorf("yanlış kodla kullanıcı oluşturuldu: %v", err)
+	}
+}
+
+func TestResetPasswordWithEmailedCode(t *testing.T) {
+	s, mailer := newTestServer()
+	const email = "zeynep@example.com"
+
+	hash, err := hashPassword("Eski-Parola-42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.users.Create(context.Background(), &User{Ad: "Zeynep", Soyad: "Demir", Email: email, Sifre: hash, Provider: "email"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := postJSON(t, s.sendPasswordResetCodeHandler, SendCodeRequest{Email: email}); rec.Code != http.StatusOK {
+		t.Fatalf("sendPasswordResetCode: %d %s", rec.Code, rec.Body)
+	}
+	code := waitForCode(t, mailer, email)
+
+	reset := ResetPasswordRequest{Email: email, Code: code, NewPassword: "Yeni-Parola-42"}
+	if rec := postJSON(t, s.resetPasswordHandler, reset); rec.Code != http.StatusOK {
+		t.Fatalf("resetPassword: %d %s", rec.Code, rec.Body)
+	}
+
+	user, err := s.users.FindByEmail(context.Background(), email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !checkPasswordHash(reset.NewPassword, user.Sifre) {
+		t.Error("yeni şifre kaydedilmedi")
+	}
+
+	// Kod tüketildiği için ikinci sıfırlama aynı kodla yapılamaz
+	if rec := postJSON(t, s.resetPasswordHandler, reset); rec.Code != http.StatusUnauthorized {
+		t.Errorf("aynı kodla ikinci sıfırlama: %d, beklenen 401", rec.Code)
+	}
+}
+
+func TestResetPasswordUnknownEmail(t *testing.T) {
+	s, _ := newTestServer()
+	if rec := postJSON(t, s.sendPasswordResetCodeHandler, SendCodeRequest{Email: "yok@example.com"}); rec.Code != http.StatusNotFound {
+		t.Errorf("bilinmeyen e-posta: %d, beklenen 404", rec.Code)
+	}
+}
+
+func TestRequestLoginCode(t *testing.T) {
+	s, mailer := newTestServer()
+	const email = "elif@example.com"
+	if err := s.users.Create(context.Background(), &User{Ad: "Elif", Soyad: "Şahin", Email: email, Provider: "google"}); err != nil {
+		t.Fatal(err)
+	}
+
+	known := postJSON(t, s.requestLoginCodeHandler, SendCodeRequest{Email: "  Elif@Example.com "})
+	unknown := postJSON(t, s.requestLoginCodeHandler, SendCodeRequest{Email: "yok@example.com"})
+	if known.Code != http.StatusOK || unknown.Code != http.StatusOK || known.Body.String() != unknown.Body.String() {
+		t.Fatalf("yanıtlar farklı: %d %s / %d %s", known.Code, known.Body, unknown.Code, unknown.Body)
+	}
+	if _, ok := mailer.Last("yok@example.com"); ok {
+		t.Error("kayıtlı olmayan adrese e-posta gönderildi")
+	}
+
+	// Giriş kodu yalnızca giriş amacıyla tüketilebilir
+	code := waitForCode(t, mailer, email)
+	if err := s.consumeVerificationCode(context.Background(), CodePurposeRegister, email, code); err != errCodeInvalid {
+		t.Errorf("kayıt amacıyla tüketme: %v, beklenen errCodeInvalid", err)
+	}
+	if err := s.consumeVerificationCode(context.Background(), CodePurposeLogin, email, code); err != nil {
+		t.Errorf("giriş kodu tüketilemedi: %v", err)
+	}
+}
+
+func TestReauthenticatePasswordlessRequiresEmailedCode(t *testing.T) {
+	s, _ := newTestServer()
+	ctx := context.Background()
+	user := &User{Ad: "Can", Email: "can@example.com", Provider: "google"}
+	if err := s.users.Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.reauthenticate(ctx, user, &DeleteAccountRequest{}) {
+		t.Error("kod olmadan kimlik doğrulandı")
+	}
+	code, err := s.issueVerificationCode(ctx, CodePurposeDelete, user.Email, deleteAccountCodeTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.reauthenticate(ctx, user, &DeleteAccountRequest{EmailCode: code}) {
+		t.Error("doğru kodla kimlik doğrulanmadı")
+	}
+	// Kod tek kullanımlıktır
+	if s.reauthenticate(ctx, user, &DeleteAccountRequest{EmailCode: code}) {
+		t.Error("aynı kod ikinci kez kabul edildi")
+	}
+}
+
+func TestResolveSocialUserClaimsLegacyGoogleRow(t *testing.T) {
+	s, mailer := newTestServer()
+	ctx := context.Background()
+	// İlk sürüm Google hesaplarında socialId alanına e-posta yazılıyordu
+	legacy := &User{Ad: "Deniz", Email: "deniz@example.com", Provider: "google", SocialID: "Deniz@example.com"}
+	if err := s.users.Create(ctx, legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	profile := &SocialProfile{Subject: "109876543210", Email: "deniz@example.com"}
+	user, err := s.resolveSocialUser(ctx, "google", profile)
+	if err != nil {
+		t.Fatalf("eski Google kaydı sahiplenilmedi: %v", err)
+	}
+	if user.ID != legacy.ID {
+		t.Fatalf("yeni hesap oluşturuldu: %s, beklenen %s", user.ID.Hex(), legacy.ID.Hex())
+	}
+	if linked, err := s.users.FindByIdentity(ctx, "google", profile.Subject); err != nil || linked.ID != legacy.ID {
+		t.Errorf("kimlik bağlanmadı: %v", err)
+	}
+	if _, ok := mailer.Last(legacy.Email); ok {
+		t.Error("eski kaydın sahiplenilmesi için bağlama e-postası gönderildi")
+	}
+
+	// Başka sağlayıcının doğrulanmamış e-postası aynı kaydı sahiplenemez
+	if _, err := s.resolveSocialUser(ctx, "facebook", &SocialProfile{Subject: "fb-1", Email: "deniz@example.com"}); err != errAccountExists {
+		t.Errorf("facebook: %v, beklenen errAccountExists", err)
+	}
+}
+
+// useTestSigningKey, testte üretilen bir Ed25519 anahtarıyla token imzalanmasını sağlar.
+func useTestSigningKey(t *testing.T) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := &signingKey{kid: "test", method: signingMethodEdDSA, privateKey: private, publicKey: public}
+	previous := jwtKeys
+	jwtKeys = &keySet{active: key, keys: map[string]*signingKey{key.kid: key}}
+	t.Cleanup(func() { jwtKeys = previous })
+}
+
+// createPasswordUser, şifreyle giriş yapabilen bir kullanıcı oluşturur. Şifre en düşük
+// bcrypt maliyetiyle özetlenir; kilit testlerindeki bekleme süreleri karşılaştırmayla dolmaz.
+func createPasswordUser(t *testing.T, s *Server, email, password string) *User {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &User{Ad: "Test", Email: email, Sifre: string(hash), Provider: "email", CreatedAt: time.Now()}
+	if err := s.users.Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func decodeTokens(t *testing.T, rec *httptest.ResponseRecorder) TokenResponse {
+	t.Helper()
+	var tokens TokenResponse
+	if err := json.NewDecoder(rec.Body).Decode(&tokens); err != nil || tokens.Token == "" || tokens.RefreshToken == "" {
+		t.Fatalf("token yanıtı okunamadı: %v", err)
+	}
+	return tokens
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	useTestSigningKey(t)
+	s, _ := newTestServer()
+	const email, password = "oturum@example.com", "Gizli-Parola-42"
+	createPasswordUser(t, s, email, password)
+
+	rec := postJSON(t, s.loginHandler, LoginRequest{Email: email, Sifre: password})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: %d %s", rec.Code, rec.Body)
+	}
+	first := decodeTokens(t, rec)
+
+	rec = postJSON(t, s.refreshTokenHandler, RefreshRequest{RefreshToken: first.RefreshToken})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("refresh: %d %s", rec.Code, rec.Body)
+	}
+	second := decodeTokens(t, rec)
+
+	// Kullanılmış token tekrar gelirse aile iptal edilir; yeni token da geçersiz olur
+	if rec := postJSON(t, s.refreshTokenHandler, RefreshRequest{RefreshToken: first.RefreshToken}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("kullanılmış refresh token: %d, beklenen 401", rec.Code)
+	}
+	if rec := postJSON(t, s.refreshTokenHandler, RefreshRequest{RefreshToken: second.RefreshToken}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("iptal edilen ailenin token'ı: %d, beklenen 401", rec.Code)
+	}
+}
+
+func TestLogoutRevokesAccessToken(t *testing.T) {
+	useTestSigningKey(t)
+	s, _ := newTestServer()
+	const email, password = "cikis@example.com", "Gizli-Parola-42"
+	createPasswordUser(t, s, email, password)
+
+	rec := postJSON(t, s.loginHandler, LoginRequest{Email: email, Sifre: password})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: %d %s", rec.Code, rec.Body)
+	}
+	tokens := decodeTokens(t, rec)
+
+	logout := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+		req.Header.Set("Authorization", "Bearer "+tokens.Token)
+		rec := httptest.NewRecorder()
+		s.logoutHandler(rec, req)
+		return rec.Code
+	}
+	if code := logout(); code != http.StatusOK {
+		t.Fatalf("logout: %d", code)
+	}
+	if code := logout(); code != http.StatusUnauthorized {
+		t.Errorf("iptal edilen access token: %d, beklenen 401", code)
+	}
+	if rec := postJSON(t, s.refreshTokenHandler, RefreshRequest{RefreshToken: tokens.RefreshToken}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("çıkıştan sonra refresh: %d, beklenen 401", rec.Code)
+	}
+}
+
+func TestLoginLockoutAfterRepeatedFailures(t *testing.T) {
+	useTestSigningKey(t)
+	s, _ := newTestServer()
+	const email, password = "kilit@example.com", "Gizli-Parola-42"
+	user := createPasswordUser(t, s, email, password)
+
+	for i := 0; i < accountLockout.BackoffAfter; i++ {
+		if rec := postJSON(t, s.loginHandler, LoginRequest{Email: email, Sifre: "yanlis"}); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("%d. hatalı deneme: %d, beklenen 401", i+1, rec.Code)
+		}
+	}
+
+	// Bekleme süresi içinde doğru şifre bile denenmez
+	rec := postJSON(t, s.loginHandler, LoginRequest{Email: email, Sifre: password})
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("bekleme süresinde giriş: %d, beklenen 429 ve Retry-After", rec.Code)
+	}
+
+	// Yönetici kilidi kaldırınca giriş yapılabilir ve sayaç sıfırlanır
+	if err := s.clearLoginFailures(context.Background(), accountLockoutKey(user.ID)); err != nil {
+		t.Fatal(err)
+	}
+	if rec := postJSON(t, s.loginHandler, LoginRequest{Email: email, Sifre: password}); rec.Code != http.StatusOK {
+		t.Fatalf("kilit kaldırıldıktan sonra giriş: %d %s", rec.Code, rec.Body)
+	}
+	if _, err := s.lockouts.Find(context.Background(), accountLockoutKey(user.ID)); err != errNotFound {
+		t.Errorf("başarılı girişten sonra sayaç kaldı: %v", err)
+	}
+}
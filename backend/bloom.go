@@ -0,0 +1,52 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+)
+
+// bloomFilter, büyük bir kelime listesini bellekte küçük yer kaplayarak sorgulamayı sağlar.
+// Yanlış pozitif olabilir (yaygın olmayan bir şifre nadiren reddedilebilir), yanlış negatif olmaz.
+type bloomFilter struct {
+	bits []uint64
+	m    uint64 // Bit sayısı
+	k    uint64 // Her öğe için hash sayısı
+}
+
+// newBloomFilter, n öğe ve istenen yanlış pozitif oranı için boyutlandırılmış bir filtre oluşturur.
+func newBloomFilter(n int, falsePositiveRate float64) *bloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	return &bloomFilter{bits: make([]uint64, (m+63)/64), m: m, k: k}
+}
+
+// locations, öğenin bit konumlarını çift hash yöntemiyle (h1 + i*h2) hesaplar.
+func (f *bloomFilter) locations(item string) (uint64, uint64) {
+	sum := sha256.Sum256([]byte(item))
+	h1 := binary.BigEndian.Uint64(sum[0:8])
+	h2 := binary.BigEndian.Uint64(sum[8:16]) | 1
+	return h1, h2
+}
+
+func (f *bloomFilter) Add(item string) {
+	h1, h2 := f.locations(item)
+	for i := uint64(0); i < f.k; i++ {
+		pos := (h1 + i*h2) % f.m
+		f.bits[pos/64] |= 1 << (pos % 64)
+	}
+}
+
+func (f *bloomFilter) Contains(item string) bool {
+	h1, h2 := f.locations(item)
+	for i := uint64(0); i < f.k; i++ {
+		pos := (h1 + i*h2) % f.m
+		if f.bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
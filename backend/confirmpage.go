@@ -0,0 +1,42 @@
+package main
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+)
+
+// confirmPage, e-postayla gönderilen tek kullanımlık bağlantılar için onay sayfasıdır. E-posta
+// tarayıcıları ve bağlantı önizleyicileri GET isteği gönderdiği için bağlantının etkisi yalnızca
+// sayfadaki formun POST isteğiyle uygulanır; GET hiçbir şeyi değiştirmez.
+type confirmPage struct {
+	Title   string
+	Message string
+	Action  string // Formun POST edileceği yol
+	Button  string
+	Token   string
+}
+
+var confirmPageTemplate = template.Must(template.New("confirm").Parse(`<!DOCTYPE html>
+<html lang="tr"><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>body{font-family:sans-serif;margin:3em auto;max-width:28em;text-align:center}button{font-size:1.1em;padding:.6em 2em}</style></head>
+<body><h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+<form method="POST" action="{{.Action}}">
+<input type="hidden" name="token" value="{{.Token}}">
+<button type="submit">{{.Button}}</button>
+</form>
+</body></html>`))
+
+// writeConfirmPage, onay sayfasını yazar. Token adres çubuğunda olduğu için sayfa önbelleğe
+// alınmaz ve Referer ile sızdırılmaz.
+func writeConfirmPage(w http.ResponseWriter, page confirmPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if err := confirmPageTemplate.Execute(w, page); err != nil {
+		log.Printf("Onay sayfası yazılamadı: %v", err)
+	}
+}
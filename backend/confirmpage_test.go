@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Bağlantı önizleyicileri GET isteği gönderir; GET yalnızca onay sayfasını göstermeli ve
+// veritabanına dokunmamalıdır (testte koleksiyonlar tanımlı olmadığı için dokunursa panikler).
+func TestEmailedLinksDoNothingOnGet(t *testing.T) {
+	s, _ := newTestServer()
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		path    string
+		action  string
+	}{
+		{"e-posta geri alma", s.undoEmailChangeHandler, "/user/email/undo?token=abc%22def", "/user/email/undo"},
+		{"hesap kilidi", s.unlockAccountHandler, "/login/unlock?token=abc%22def", "/login/unlock"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tc.handler(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("durum = %d", rec.Code)
+			}
+			body := rec.Body.String()
+			if !strings.Contains(body, `<form method="POST" action="`+tc.action+`">`) {
+				t.Errorf("POST formu yok: %s", body)
+			}
+			if !strings.Contains(body, `value="abc&#34;def"`) {
+				t.Errorf("token kaçışlanmadan yazıldı: %s", body)
+			}
+			if rec.Header().Get("Cache-Control") != "no-store" || rec.Header().Get("Referrer-Policy") != "no-referrer" {
+				t.Errorf("başlıklar: %v", rec.Header())
+			}
+		})
+	}
+}
@@ -13,10 +13,13 @@ import (
 
 // Global MongoDB bağlantı ve koleksiyon referansları
 var (
-	client                 *mongo.Client
-	database               *mongo.Database
-	usersCollection        *mongo.Collection
-	verificationCollection *mongo.Collection // Bu, sizin projenizdeki doğru koleksiyon adı
+	client   *mongo.Client
+	database *mongo.Database
+
+	webauthnCredentialsCollection *mongo.Collection
+	webauthnSessionsCollection    *mongo.Collection
+	magicLinksCollection          *mongo.Collection
+	emailChangesCollection        *mongo.Collection
 )
 
 // Global değişkenler için mutex
@@ -59,8 +62,10 @@ func InitMongoDB() {
 
 	// Veritabanı ve koleksiyonları başlat
 	database = client.Database("eventra") // Veritabanı adını kontrol edin
-	usersCollection = database.Collection("users")
-	verificationCollection = database.Collection("verification_codes")
+	webauthnCredentialsCollection = database.Collection("webauthn_credentials")
+	webauthnSessionsCollection = database.Collection("webauthn_sessions")
+	magicLinksCollection = database.Collection("magic_links")
+	emailChangesCollection = database.Collection("email_changes")
 
 	isDBInit = true
 }
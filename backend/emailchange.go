@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	emailChangeCodeTTL = 10 * time.Minute
+	emailChangeUndoTTL = 7 * 24 * time.Hour
+)
+
+var errEmailTaken = errors.New("e-posta adresi başka bir hesapta kullanılıyor")
+
+// requestEmailChangeHandler, yeni adrese doğrulama kodu gönderir. Değişiklik kod onaylanana
+// kadar uygulanmaz; önceki bekleyen istek varsa yenisiyle değiştirilir.
+func (s *Server) requestEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims, ok := s.authenticateRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req EmailChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Geçersiz JSON formatı"}`, http.StatusBadRequest)
+		return
+	}
+
+	newEmail := normalizeEmail(req.Email)
+	if addr, err := mail.ParseAddress(newEmail); err != nil || addr.Address != newEmail {
+		writeValidationErrors(w, []FieldError{{Field: "email", Code: "invalid", Message: "Geçerli bir e-posta adresi girin"}})
+		return
+	}
+
+	user, err := s.getUserByClaims(claims)
+	if err != nil {
+		http.Error(w, `{"error": "Kullanıcı bulunamadı"}`, http.StatusNotFound)
+		return
+	}
+	if newEmail == user.Email {
+		writeValidationErrors(w, []FieldError{{Field: "email", Code: "same_as_current", Message: "Yeni adres mevcut adresinizle aynı"}})
+		return
+	}
+	// Şifreli hesaplarda çalınmış bir token ile adres değiştirilemesin diye şifre tekrar sorulur
+	if user.Sifre != "" && !checkPasswordHash(req.Sifre, user.Sifre) {
+		http.Error(w, `{"error": "Hatalı şifre"}`, http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	taken, err := s.users.EmailExists(ctx, newEmail)
+	if err != nil {
+		log.Printf("Veritabanı hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+	if taken {
+		http.Error(w, `{"error": "Bu e-posta adresi başka bir hesapta kullanılıyor"}`, http.StatusConflict)
+		return
+	}
+
+	code, err := s.issueVerificationCode(ctx, CodePurposeEmailChange, newEmail, emailChangeCodeTTL)
+	if cooldown, ok := err.(*CodeCooldownError); ok {
+		writeRetryAfter(w, cooldown.RetryAfter)
+		http.Error(w, `{"error": "Yeni kod istemeden önce lütfen bekleyin"}`, http.StatusTooManyRequests)
+		return
+	} else if err != nil {
+		log.Printf("Doğrulama kodu kaydetme hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	_, err = emailChangesCollection.DeleteMany(ctx, bson.M{"userId": user.ID, "confirmedAt": bson.M{"$exists": false}})
+	if err == nil {
+		_, err = emailChangesCollection.InsertOne(ctx, EmailChange{
+			UserID:    user.ID,
+			OldEmail:  user.Email,
+			NewEmail:  newEmail,
+			ExpiresAt: now.Add(emailChangeCodeTTL),
+			CreatedAt: now,
+		})
+	}
+	if err != nil {
+		log.Printf("E-posta değişikliği kaydetme hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+
+	err = s.sendEmail(newEmail, "E-posta Adresi Doğrulama Kodunuz", fmt.Sprintf("Eventra hesabınızın e-posta adresini bu adresle değiştirmek için doğrulama kodunuz: %s", code))
+	if err != nil {
+		log.Printf("E-posta gönderme hatası: %v", err)
+		http.Error(w, `{"error": "E-posta gönderme başarısız"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MessageResponse{Message: "Doğrulama kodu yeni adresinize gönderildi"})
+}
+
+// confirmEmailChangeHandler, kodu doğrular ve yeni adresi uygular. Token'lar e-posta
+// içerdiği için isteği yapan cihaza yeni bir token ikilisi verilir; diğer cihazlar bir
+// sonraki yenilemede güncel adresi alır.
+func (s *Server) confirmEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims, ok := s.authenticateRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req EmailConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Geçersiz JSON formatı"}`, http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.getUserByClaims(claims)
+	if err != nil {
+		http.Error(w, `{"error": "Kullanıcı bulunamadı"}`, http.StatusNotFound)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var change EmailChange
+	err = emailChangesCollection.FindOne(ctx, bson.M{
+		"userId":      user.ID,
+		"confirmedAt": bson.M{"$exists": false},
+		"expiresAt":   bson.M{"$gt": time.Now()},
+	}).Decode(&change)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, `{"error": "Bekleyen e-posta değişikliği yok veya süresi dolmuş"}`, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Printf("Veritabanı hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+
+	err = s.consumeVerificationCode(ctx, CodePurposeEmailChange, change.NewEmail, req.Code)
+	if err == errCodeTooManyAttempts {
+		http.Error(w, `{"error": "Çok fazla hatalı deneme, lütfen yeni kod isteyin"}`, http.StatusTooManyRequests)
+		return
+	} else if err == errCodeInvalid {
+		http.Error(w, `{"error": "Doğrulama kodu hatalı veya süresi dolmuş"}`, http.StatusUnauthorized)
+		return
+	} else if err != nil {
+		log.Printf("Doğrulama kodu kontrol hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+
+	undoToken, err := generateOpaqueToken()
+	if err != nil {
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+
+	// Bekleyen değişiklik de yalnızca bir kez onaylanabilir
+	now := time.Now()
+	undoExpiresAt := now.Add(emailChangeUndoTTL)
+	result, err := emailChangesCollection.UpdateOne(ctx,
+		bson.M{"_id": change.ID, "confirmedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{
+			"confirmedAt":   now,
+			"undoTokenHash": hashToken(undoToken),
+			"undoExpiresAt": undoExpiresAt,
+		}},
+	)
+	if err != nil || result.MatchedCount == 0 {
+		http.Error(w, `{"error": "Bekleyen e-posta değişikliği yok veya süresi dolmuş"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := s.users.ChangeEmail(ctx, change.UserID, change.OldEmail, change.NewEmail); err != nil {
+		// Değişiklik uygulanamadıysa kayıt yeniden bekleyen duruma alınır
+		emailChangesCollection.UpdateOne(ctx, bson.M{"_id": change.ID}, bson.M{
+			"$unset": bson.M{"confirmedAt": "", "undoTokenHash": "", "undoExpiresAt": ""},
+		})
+		if err == errEmailTaken {
+			http.Error(w, `{"error": "Bu e-posta adresi başka bir hesapta kullanılıyor"}`, http.StatusConflict)
+			return
+		}
+		log.Printf("E-posta güncelleme hatası: %v", err)
+		http.Error(w, `{"error": "E-posta adresi güncellenemedi"}`, http.StatusInternalServerError)
+		return
+	}
+
+	undoLink := publicBaseURL(r) + "/user/email/undo?token=" + url.QueryEscape(undoToken)
+	go func() {
+		body := fmt.Sprintf("Merhaba %s,\n\nEventra hesabınızın e-posta adresi %s olarak değiştirildi.\n\nBu işlemi siz yapmadıysanız aşağıdaki bağlantıyla değişikliği %d gün içinde geri alabilirsiniz. Geri alındığında tüm oturumlarınız kapatılır:\n\n%s", user.Ad, change.NewEmail, int(emailChangeUndoTTL.Hours()/24), undoLink)
+		if err := s.sendEmail(change.OldEmail, "E-posta adresiniz değiştirildi", body); err != nil {
+			log.Printf("E-posta gönderim hatası (asenkron): %v", err)
+		}
+	}()
+
+	// Eski e-postayı taşıyan token'lar yerine yenileri verilir
+	if claims.SessionID != "" {
+		if err := s.revokeFamily(ctx, claims.SessionID); err != nil {
+			log.Printf("Oturum iptal hatası: %v", err)
+		}
+	}
+	if err := s.revokeToken(ctx, claims.Id, time.Unix(claims.ExpiresAt, 0)); err != nil {
+		log.Printf("Token iptal hatası: %v", err)
+	}
+
+	user.Email = change.NewEmail
+	pair, err := s.issueTokenPair(ctx, user, r)
+	if err != nil {
+		log.Printf("Token oluşturma hatası: %v", err)
+		http.Error(w, `{"error": "Token oluşturulamadı"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(TokenResponse{
+		Status:       "success",
+		Message:      "E-posta adresiniz güncellendi",
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
+	})
+}
+
+// undoEmailChangeHandler, eski adrese gönderilen bağlantıyla değişikliği geri alır. Bağlantı
+// açıldığında (GET) yalnızca onay sayfası gösterilir; geri alma sayfadaki formun POST isteğiyle
+// yapılır. Hesap ele geçirilmiş olabileceği için tüm oturumlar kapatılır.
+func (s *Server) undoEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		writeConfirmPage(w, confirmPage{
+			Title:   "E-posta değişikliğini geri al",
+			Message: "Hesabınızın e-posta adresi eski adresinize döndürülecek ve tüm oturumlarınız kapatılacak.",
+			Action:  "/user/email/undo",
+			Button:  "Değişikliği geri al",
+			Token:   r.URL.Query().Get("token"),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	token := r.PostFormValue("token")
+	if token == "" {
+		http.Error(w, "Geçersiz bağlantı", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	var change EmailChange
+	err := emailChangesCollection.FindOneAndUpdate(ctx,
+		bson.M{
+			"undoTokenHash": hashToken(token),
+			"undoneAt":      bson.M{"$exists": false},
+			"undoExpiresAt": bson.M{"$gt": now},
+		},
+		bson.M{"$set": bson.M{"undoneAt": now}},
+	).Decode(&change)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Bağlantı geçersiz, kullanılmış veya süresi dolmuş", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Printf("Veritabanı hatası: %v", err)
+		http.Error(w, "Sunucu hatası", http.StatusInternalServerError)
+		return
+	}
+
+	// Ters yönde aynı kurallarla uygulanır: eski adres bu sırada başka bir hesaba alındıysa geri alınamaz
+	err = s.users.ChangeEmail(ctx, change.UserID, change.NewEmail, change.OldEmail)
+	if err != nil {
+		emailChangesCollection.UpdateOne(ctx, bson.M{"_id": change.ID}, bson.M{"$unset": bson.M{"undoneAt": ""}})
+		if err == errEmailTaken || err == errNotFound {
+			http.Error(w, "Değişiklik geri alınamadı, lütfen bizimle iletişime geçin", http.StatusConflict)
+			return
+		}
+		log.Printf("E-posta geri alma hatası: %v", err)
+		http.Error(w, "Sunucu hatası", http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.revokeAllUserTokens(ctx, change.UserID); err != nil {
+		log.Printf("Oturum iptal hatası: %v", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "E-posta adresiniz geri alındı ve tüm oturumlarınız kapatıldı. Güvenliğiniz için lütfen uygulamadan \"Şifremi unuttum\" adımıyla yeni bir şifre belirleyin.")
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Google'ın ID token imzalama anahtarları ve kabul edilen issuer değerleri
+const googleJWKSURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+var googleIssuers = []string{"accounts.google.com", "https://accounts.google.com"}
+
+// googleAudiences, kabul edilen istemci kimlikleridir: web istemcisi (GOOGLE_CLIENT_ID) ile
+// Android/iOS istemcileri (GOOGLE_NATIVE_CLIENT_IDS, virgülle ayrılmış).
+func googleAudiences() []string {
+	var audiences []string
+	if id := os.Getenv("GOOGLE_CLIENT_ID"); id != "" {
+		audiences = append(audiences, id)
+	}
+	for _, id := range strings.Split(os.Getenv("GOOGLE_NATIVE_CLIENT_IDS"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			audiences = append(audiences, id)
+		}
+	}
+	return audiences
+}
+
+// googleTokenHandler, native Google SDK'sından alınan ID token ile giriş yapar. Tarayıcı
+// yönlendirmesi gerekmez; yanıt /login ile aynı biçimdedir. Anahtar kaynağı dışarıdan
+// verildiği için testlerde yerel bir anahtar kümesi kullanılabilir.
+func (s *Server) googleTokenHandler(keys KeySource) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "Yalnızca POST destekleniyor", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req GoogleTokenRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.IDToken == "" {
+			http.Error(w, "Geçersiz istek gövdesi", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		claims, err := verifyIDToken(ctx, req.IDToken, keys, IDTokenExpectations{
+			Issuers:   googleIssuers,
+			Audiences: googleAudiences(),
+			Nonce:     req.Nonce,
+		})
+		if err != nil {
+			log.Printf("Google ID token doğrulama hatası: %v", err)
+			s.recordLoginEvent(r, nil, "", googleLogin.Name(), false, "invalid_id_token")
+			http.Error(w, "Geçersiz Google token", http.StatusUnauthorized)
+			return
+		}
+
+		str := func(name string) string {
+			v, _ := claims[name].(string)
+			return v
+		}
+		profile := &SocialProfile{
+			Subject:       str("sub"),
+			Email:         normalizeEmail(str("email")),
+			EmailVerified: claimBool(claims["email_verified"]),
+			GivenName:     str("given_name"),
+			FamilyName:    str("family_name"),
+		}
+		if profile.Email == "" {
+			http.Error(w, "Google hesabı e-posta adresi paylaşmadı", http.StatusBadRequest)
+			return
+		}
+
+		user, err := s.resolveSocialUser(ctx, googleLogin.Name(), profile)
+		if err == errAccountExists {
+			s.recordLoginEvent(r, nil, profile.Email, googleLogin.Name(), false, "account_exists")
+			http.Error(w, "Bu e-posta ile kayıtlı bir hesap var; önce giriş yapıp Google hesabınızı bağlayın", http.StatusConflict)
+			return
+		} else if err != nil {
+			log.Printf("Sosyal kullanıcı kaydetme hatası: %v", err)
+			http.Error(w, "Kayıt başarısız", http.StatusInternalServerError)
+			return
+		}
+		if err := checkUserActive(user); err != nil {
+			s.recordLoginEvent(r, user, user.Email, googleLogin.Name(), false, inactiveReason(err))
+			http.Error(w, "Bu hesap kullanıma kapalı", http.StatusForbidden)
+			return
+		}
+
+		pair, err := s.issueLoginTokens(ctx, user, r, googleLogin.Name())
+		if err != nil {
+			log.Printf("Token oluşturma hatası: %v", err)
+			http.Error(w, "Token oluşturulamadı", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(TokenResponse{
+			Status:       "success",
+			Message:      "Giriş başarılı",
+			Token:        pair.AccessToken,
+			RefreshToken: pair.RefreshToken,
+			ExpiresIn:    pair.ExpiresIn,
+		})
+	}
+}
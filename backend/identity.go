@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const linkTicketTTL = 5 * time.Minute
+
+var (
+	errAccountExists         = errors.New("bu e-posta ile kayıtlı bir hesap var")
+	errProviderAlreadyLinked = errors.New("bu sağlayıcıdan zaten bir hesap bağlı")
+	errLastLoginMethod       = errors.New("son giriş yöntemi kaldırılamaz")
+)
+
+// resolveSocialUser, sosyal girişle gelen kullanıcıyı tek bir User kaydına eşler:
+//  1. Bu sağlayıcı kimliği bağlı bir kullanıcı varsa o döner.
+//  2. Aynı e-postayla bir hesap varsa ve sağlayıcı e-postayı doğrulamışsa kimlik o hesaba
+//     bağlanır. Doğrulanmamış e-postayla bağlama yapılmaz; aksi halde başkasının adresini
+//     kullanan biri mevcut hesabı ele geçirebilirdi.
+//  3. Hiçbiri yoksa yeni kullanıcı oluşturulur.
+func (s *Server) resolveSocialUser(ctx context.Context, provider string, profile *SocialProfile) (*User, error) {
+	if profile.Subject == "" {
+		return nil, errors.New("sağlayıcı kullanıcı kimliği boş")
+	}
+	identity := Identity{
+		Provider: provider,
+		Subject:  profile.Subject,
+		Email:    profile.Email,
+		LinkedAt: time.Now(),
+	}
+
+	user, err := s.users.FindByIdentity(ctx, provider, profile.Subject)
+	if err != errNotFound {
+		return user, err
+	}
+
+	user, err = s.users.FindByEmail(ctx, profile.Email)
+	if err == nil {
+		// Bağlama öncesi sürümde aynı sağlayıcıyla oluşturulmuş kayıtlar doğrudan sahiplenilir.
+		// İlk sürüm Google kayıtlarında socialId alanına sağlayıcı kimliği yerine e-postayı yazıyordu.
+		legacy := user.Provider == provider && len(user.Identities) == 0 &&
+			(user.SocialID == profile.Subject || (provider == "google" && normalizeEmail(user.SocialID) == profile.Email))
+		if !legacy && !profile.EmailVerified {
+			return nil, errAccountExists
+		}
+		if err := s.users.AddIdentity(ctx, user.ID, identity); err != nil {
+			return nil, err
+		}
+		user.Identities = append(user.Identities, identity)
+		if !legacy {
+			s.notifyIdentityLinked(user, provider)
+		}
+		return user, nil
+	} else if err != errNotFound {
+		return nil, err
+	}
+
+	user = &User{
+		Ad:         profile.GivenName,
+		Soyad:      profile.FamilyName,
+		Email:      profile.Email,
+		Provider:   provider,
+		SocialID:   profile.Subject,
+		Identities: []Identity{identity},
+		CreatedAt:  time.Now(),
+	}
+	if err := s.users.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// notifyIdentityLinked, hesaba yeni bir giriş yöntemi eklendiğinde kullanıcıyı bilgilendirir.
+func (s *Server) notifyIdentityLinked(user *User, provider string) {
+	go func() {
+		body := fmt.Sprintf("Merhaba %s,\n\nHesabınıza %s ile giriş bağlandı. Bu işlemi siz yapmadıysanız lütfen şifrenizi değiştirin ve bizimle iletişime geçin.\n\nİyi günler.", user.Ad, provider)
+		if err := s.sendEmail(user.Email, "Hesabınıza yeni giriş yöntemi eklendi", body); err != nil {
+			log.Printf("E-posta gönderim hatası (asenkron): %v", err)
+		}
+	}()
+}
+
+// finishIdentityLink, hesap bağlama akışının geri dönüşünü tamamlar ve uygulamaya yönlendirir.
+func (s *Server) finishIdentityLink(w http.ResponseWriter, r *http.Request, state *OAuthState, provider string, profile *SocialProfile) {
+	ctx := context.Background()
+
+	owner, err := s.users.FindByIdentity(ctx, provider, profile.Subject)
+	if err == nil {
+		if owner.ID != state.LinkUserID {
+			redirectWithError(w, r, state.RedirectURI, "identity_in_use")
+			return
+		}
+	} else if err == errNotFound {
+		err = s.users.AddIdentity(ctx, state.LinkUserID, Identity{
+			Provider: provider,
+			Subject:  profile.Subject,
+			Email:    profile.Email,
+			LinkedAt: time.Now(),
+		})
+		if err == errProviderAlreadyLinked {
+			redirectWithError(w, r, state.RedirectURI, "provider_already_linked")
+			return
+		}
+	}
+	if err != nil && err != errNotFound {
+		log.Printf("Hesap bağlama hatası: %v", err)
+		http.Error(w, "Sunucu hatası", http.StatusInternalServerError)
+		return
+	}
+
+	redirectURL, err := buildLoginRedirect(state.RedirectURI, map[string]string{"linked": provider})
+	if err != nil {
+		http.Error(w, "Yönlendirme adresi oluşturulamadı", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+}
+
+// redirectWithError, tarayıcı akışlarındaki hataları uygulamaya "error" parametresiyle bildirir.
+func redirectWithError(w http.ResponseWriter, r *http.Request, base, code string) {
+	redirectURL, err := buildLoginRedirect(base, map[string]string{"error": code})
+	if err != nil {
+		http.Error(w, "Yönlendirme adresi oluşturulamadı", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+}
+
+// publicBaseURL, e-postalarda ve yönlendirmelerde kullanılan dış adresi döndürür.
+func publicBaseURL(r *http.Request) string {
+	if base := os.Getenv("PUBLIC_BASE_URL"); base != "" {
+		return strings.TrimSuffix(base, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// listIdentitiesHandler, kullanıcının bağlı giriş yöntemlerini döndürür.
+func (s *Server) listIdentitiesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims, ok := s.authenticateRequest(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := s.getUserByClaims(claims)
+	if err != nil {
+		http.Error(w, `{"error": "Kullanıcı bulunamadı"}`, http.StatusNotFound)
+		return
+	}
+
+	identities := user.Identities
+	if identities == nil {
+		identities = []Identity{}
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"identities":  identities,
+		"hasPassword": user.Sifre != "",
+	})
+}
+
+// linkIdentityHandler, hesap bağlama akışını başlatmak için tarayıcıda açılacak adresi döndürür.
+// Adres, oturum açmış kullanıcıya özel kısa ömürlü bir bilet içerir.
+func (s *Server) linkIdentityHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims, ok := s.authenticateRequest(w, r)
+	if !ok {
+		return
+	}
+
+	provider := mux.Vars(r)["provider"]
+	if _, exists := oauthProviders[provider]; !exists {
+		http.Error(w, `{"error": "Bilinmeyen sağlayıcı"}`, http.StatusNotFound)
+		return
+	}
+
+	redirectURI, err := resolveLoginRedirect(r.URL.Query().Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, `{"error": "Yönlendirme adresine izin verilmiyor"}`, http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.getUserByClaims(claims)
+	if err != nil {
+		http.Error(w, `{"error": "Kullanıcı bulunamadı"}`, http.StatusNotFound)
+		return
+	}
+
+	ticket, err := createPurposeToken(user, "link:"+provider, linkTicketTTL)
+	if err != nil {
+		log.Printf("Bağlama bileti oluşturma hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+
+	query := url.Values{}
+	query.Set("link_ticket", ticket)
+	query.Set("redirect_uri", redirectURI)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"url": publicBaseURL(r) + "/" + provider + "/login?" + query.Encode(),
+	})
+}
+
+// unlinkIdentityHandler, bağlı bir sosyal hesabı kaldırır. Kullanıcının başka giriş yöntemi
+// (şifre veya başka bir sağlayıcı) yoksa işlem reddedilir.
+func (s *Server) unlinkIdentityHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims, ok := s.authenticateRequest(w, r)
+	if !ok {
+		return
+	}
+
+	provider := mux.Vars(r)["provider"]
+	user, err := s.getUserByClaims(claims)
+	if err != nil {
+		http.Error(w, `{"error": "Kullanıcı bulunamadı"}`, http.StatusNotFound)
+		return
+	}
+
+	err = s.removeIdentity(context.Background(), user, provider)
+	if err == errNotFound {
+		http.Error(w, `{"error": "Bu sağlayıcı hesabınıza bağlı değil"}`, http.StatusNotFound)
+		return
+	} else if err == errLastLoginMethod {
+		http.Error(w, `{"error": "Son giriş yönteminizi kaldıramazsınız"}`, http.StatusConflict)
+		return
+	} else if err != nil {
+		log.Printf("Hesap bağlantısı kaldırma hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MessageResponse{Message: "Hesap bağlantısı kaldırıldı"})
+}
+
+// removeIdentity, kimliği kaldırır. Şifresiz hesaplarda son kimlik kaldırılamaz; kontrol
+// store'da atomik yapıldığı için eşzamanlı iki istek son iki yöntemi birden silemez.
+func (s *Server) removeIdentity(ctx context.Context, user *User, provider string) error {
+	linked := false
+	for _, identity := range user.Identities {
+		if identity.Provider == provider {
+			linked = true
+		}
+	}
+	if !linked {
+		return errNotFound
+	}
+	return s.users.RemoveIdentity(ctx, user.ID, provider, user.Sifre == "")
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+var errIDTokenInvalid = errors.New("ID token geçersiz")
+
+// KeySource, harici bir kimlik sağlayıcısının imza anahtarlarını kid'e göre döndürür.
+// Testlerde yerel bir anahtar kümesi verilebilmesi için arayüz olarak tanımlanmıştır.
+type KeySource interface {
+	PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// StaticKeySet, sabit anahtarlardan oluşan bir KeySource'tur.
+type StaticKeySet map[string]crypto.PublicKey
+
+func (s StaticKeySet) PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	key, ok := s[kid]
+	if !ok {
+		return nil, errUnknownKeyID
+	}
+	return key, nil
+}
+
+// RemoteKeySet, bir JWKS adresinden anahtarları çeker ve önbellekte tutar. Bilinmeyen bir
+// kid geldiğinde (sağlayıcı anahtar döndürmüş olabilir) önbellek erkenden yenilenir.
+type RemoteKeySet struct {
+	url    string
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+const (
+	jwksCacheTTL       = time.Hour
+	jwksMinRefreshWait = time.Minute // Bilinmeyen kid'lerle sağlayıcıya yük bindirilmesini önler
+)
+
+// NewRemoteKeySet, verilen JWKS adresi için önbellekli bir anahtar kaynağı oluşturur.
+func NewRemoteKeySet(url string, client *http.Client) *RemoteKeySet {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &RemoteKeySet{url: url, client: client}
+}
+
+func (s *RemoteKeySet) PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	age := time.Since(s.fetchedAt)
+	if key, ok := s.keys[kid]; ok && age < jwksCacheTTL {
+		return key, nil
+	}
+	if s.keys == nil || age >= jwksMinRefreshWait {
+		keys, err := s.fetch(ctx)
+		if err != nil {
+			// Sağlayıcıya ulaşılamazsa eldeki anahtarlarla devam edilir
+			if key, ok := s.keys[kid]; ok {
+				return key, nil
+			}
+			return nil, err
+		}
+		s.keys = keys
+		s.fetchedAt = time.Now()
+	}
+
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, errUnknownKeyID
+	}
+	return key, nil
+}
+
+func (s *RemoteKeySet) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: beklenmeyen durum kodu %d", s.url, resp.StatusCode)
+	}
+
+	var set JWKSet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue // Desteklenmeyen anahtar türleri atlanır
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+// publicKey, JWK'yi Go açık anahtar türüne çevirir.
+func (k JWK) publicKey() (crypto.PublicKey, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("desteklenmeyen eğri %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("desteklenmeyen eğri %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("desteklenmeyen anahtar türü %q", k.Kty)
+}
+
+// IDTokenExpectations, bir ID token'ın doğrulanmasında beklenen değerlerdir.
+type IDTokenExpectations struct {
+	Issuers   []string
+	Audiences []string
+	Nonce     string // Boşsa kontrol edilmez
+}
+
+// verifyIDToken, harici bir sağlayıcının ID token'ını imza, süre, iss, aud ve nonce
+// açısından doğrular ve claim'leri döndürür.
+func verifyIDToken(ctx context.Context, raw string, keys KeySource, expect IDTokenExpectations) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := keys.PublicKey(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		// Algoritma anahtar türüyle uyuşmalı; HS256 ve "none" hiçbir zaman kabul edilmez
+		switch key.(type) {
+		case *rsa.PublicKey:
+			if _, ok := token.Method.(*jwt.SigningMethodRSA); ok {
+				return key, nil
+			}
+		case *ecdsa.PublicKey:
+			if _, ok := token.Method.(*jwt.SigningMethodECDSA); ok {
+				return key, nil
+			}
+		case ed25519.PublicKey:
+			if token.Method == signingMethodEdDSA {
+				return key, nil
+			}
+		}
+		return nil, jwt.ErrSignatureInvalid
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	issuer, _ := claims["iss"].(string)
+	if !containsString(expect.Issuers, issuer) {
+		return nil, fmt.Errorf("%w: beklenmeyen iss %q", errIDTokenInvalid, issuer)
+	}
+
+	audiences := claimStrings(claims["aud"])
+	matched := false
+	for _, aud := range audiences {
+		if containsString(expect.Audiences, aud) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return nil, fmt.Errorf("%w: aud eşleşmedi", errIDTokenInvalid)
+	}
+	// Birden fazla alıcı varsa token'ın bizim için verildiği azp ile doğrulanır
+	if azp, ok := claims["azp"].(string); ok && len(audiences) > 1 && !containsString(expect.Audiences, azp) {
+		return nil, fmt.Errorf("%w: azp eşleşmedi", errIDTokenInvalid)
+	}
+
+	if _, ok := claims["exp"]; !ok {
+		return nil, fmt.Errorf("%w: exp eksik", errIDTokenInvalid)
+	}
+	if expect.Nonce != "" {
+		if nonce, _ := claims["nonce"].(string); nonce != expect.Nonce {
+			return nil, fmt.Errorf("%w: nonce eşleşmedi", errIDTokenInvalid)
+		}
+	}
+	return claims, nil
+}
+
+// claimStrings, tek bir string ya da string dizisi olabilen claim'leri listeye çevirir.
+func claimStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// claimBool, bazı sağlayıcıların "true" metni olarak gönderdiği boolean claim'leri okur.
+func claimBool(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
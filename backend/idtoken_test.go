@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const testGoogleClientID = "test-client.apps.googleusercontent.com"
+
+// googleTestKeys, Google'ın JWKS'i yerine kullanılan yerel bir RSA anahtarıdır.
+func googleTestKeys(t *testing.T) (*rsa.PrivateKey, KeySource) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, StaticKeySet{"google-test": &key.PublicKey}
+}
+
+func googleTestClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            "https://accounts.google.com",
+		"aud":            testGoogleClientID,
+		"sub":            "1234567890",
+		"email":          "ayse@example.com",
+		"email_verified": true,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          "n-0S6_WzA2Mj",
+	}
+}
+
+func signTestIDToken(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func googleExpectations(nonce string) IDTokenExpectations {
+	return IDTokenExpectations{Issuers: googleIssuers, Audiences: googleAudiences(), Nonce: nonce}
+}
+
+func TestVerifyIDTokenAcceptsValidGoogleToken(t *testing.T) {
+	t.Setenv("GOOGLE_CLIENT_ID", testGoogleClientID)
+	key, keys := googleTestKeys(t)
+
+	raw := signTestIDToken(t, key, "google-test", googleTestClaims())
+	claims, err := verifyIDToken(context.Background(), raw, keys, googleExpectations("n-0S6_WzA2Mj"))
+	if err != nil {
+		t.Fatalf("geçerli token reddedildi: %v", err)
+	}
+	if claims["sub"] != "1234567890" || !claimBool(claims["email_verified"]) {
+		t.Errorf("beklenmeyen claim'ler: %v", claims)
+	}
+}
+
+func TestVerifyIDTokenRejects(t *testing.T) {
+	t.Setenv("GOOGLE_CLIENT_ID", testGoogleClientID)
+	key, keys := googleTestKeys(t)
+	otherKey, _ := googleTestKeys(t)
+
+	tests := []struct {
+		name   string
+		kid    string
+		key    *rsa.PrivateKey
+		modify func(jwt.MapClaims)
+		nonce  string
+		want   error  // nil ise yalnızca hata dönmesi beklenir
+		flags  uint32 // jwt.ValidationError bayrakları
+	}{
+		{name: "yanlış aud", modify: func(c jwt.MapClaims) { c["aud"] = "baska-istemci.apps.googleusercontent.com" }, want: errIDTokenInvalid},
+		{name: "yanlış iss", modify: func(c jwt.MapClaims) { c["iss"] = "https://accounts.example.com" }, want: errIDTokenInvalid},
+		{name: "süresi dolmuş", modify: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, flags: jwt.ValidationErrorExpired},
+		{name: "exp eksik", modify: func(c jwt.MapClaims) { delete(c, "exp") }, want: errIDTokenInvalid},
+		{name: "bilinmeyen kid", kid: "rotated-away", want: errUnknownKeyID},
+		{name: "başka anahtarla imzalı", key: otherKey, flags: jwt.ValidationErrorSignatureInvalid},
+		{name: "yanlış nonce", nonce: "baska-nonce", want: errIDTokenInvalid},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := googleTestClaims()
+			if tc.modify != nil {
+				tc.modify(claims)
+			}
+			kid, signer, nonce := "google-test", key, "n-0S6_WzA2Mj"
+			if tc.kid != "" {
+				kid = tc.kid
+			}
+			if tc.key != nil {
+				signer = tc.key
+			}
+			if tc.nonce != "" {
+				nonce = tc.nonce
+			}
+
+			raw := signTestIDToken(t, signer, kid, claims)
+			_, err := verifyIDToken(context.Background(), raw, keys, googleExpectations(nonce))
+			if err == nil {
+				t.Fatal("token kabul edildi")
+			}
+			if tc.flags != 0 {
+				var validation *jwt.ValidationError
+				if !errors.As(err, &validation) || validation.Errors&tc.flags == 0 {
+					t.Errorf("hata = %v, beklenen bayrak %d", err, tc.flags)
+				}
+			}
+			if tc.want != nil && !errors.Is(err, tc.want) {
+				var validation *jwt.ValidationError
+				if !errors.As(err, &validation) || !errors.Is(validation.Inner, tc.want) {
+					t.Errorf("hata = %v, beklenen %v", err, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenRejectsHMACWithPublicKey(t *testing.T) {
+	t.Setenv("GOOGLE_CLIENT_ID", testGoogleClientID)
+	_, keys := googleTestKeys(t)
+
+	// Açık anahtarı HMAC sırrı olarak kullanan sahte bir token kabul edilmemeli
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, googleTestClaims())
+	token.Header["kid"] = "google-test"
+	raw, err := token.SignedString([]byte("public-key-as-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifyIDToken(context.Background(), raw, keys, googleExpectations("")); err == nil {
+		t.Fatal("HS256 token kabul edildi")
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Anahtar dizini düzeni (JWT_KEYS_DIR):
+//
+//	<kid>.pem      özel anahtar (RSA PKCS#1/PKCS#8 veya Ed25519 PKCS#8); imzalayabilir ve doğrular
+//	<kid>.pub.pem  yalnızca açık anahtar; imzalamadan çekilmiş, eski token'ları doğrulamaya devam eder
+//
+// İmzalamada JWT_ACTIVE_KID kullanılır; tanımlı değilse alfabetik olarak en büyük kid seçilir
+// (ör. "2025-01", "2025-07"). Bir anahtarı tamamen emekliye ayırmak için dosyası silinir.
+//
+// Yeni anahtar üretmek için: openssl genpkey -algorithm ed25519 -out <kid>.pem
+
+// SigningMethodEdDSA, jwt-go v3'te bulunmayan Ed25519 imzalarını ekler.
+type SigningMethodEdDSA struct{}
+
+var signingMethodEdDSA = &SigningMethodEdDSA{}
+
+func init() {
+	jwt.RegisterSigningMethod(signingMethodEdDSA.Alg(), func() jwt.SigningMethod {
+		return signingMethodEdDSA
+	})
+}
+
+func (m *SigningMethodEdDSA) Alg() string {
+	return "EdDSA"
+}
+
+func (m *SigningMethodEdDSA) Verify(signingString, signature string, key interface{}) error {
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(publicKey, []byte(signingString), sig) {
+		return jwt.ErrSignatureInvalid
+	}
+	return nil
+}
+
+func (m *SigningMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+	return jwt.EncodeSegment(ed25519.Sign(privateKey, []byte(signingString))), nil
+}
+
+// signingKey, anahtar dizininden yüklenen tek bir anahtardır.
+type signingKey struct {
+	kid        string
+	method     jwt.SigningMethod
+	privateKey crypto.Signer // Yalnızca açık anahtar dosyalarında nil
+	publicKey  crypto.PublicKey
+}
+
+// keySet, imzalama için etkin anahtarı ve doğrulamada kabul edilen tüm anahtarları tutar.
+type keySet struct {
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+var jwtKeys *keySet
+
+var errUnknownKeyID = errors.New("bilinmeyen anahtar kimliği (kid)")
+
+// InitKeys, JWT_KEYS_DIR altındaki anahtarları yükler. Geçerli bir imzalama anahtarı yoksa
+// sunucu başlatılmaz.
+func InitKeys() {
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		log.Fatal("JWT_KEYS_DIR ortam değişkeni tanımlı değil.")
+	}
+
+	ks, err := loadKeySet(dir, os.Getenv("JWT_ACTIVE_KID"))
+	if err != nil {
+		log.Fatal("JWT anahtarları yüklenemedi:", err)
+	}
+	jwtKeys = ks
+
+	log.Printf("%d JWT anahtarı yüklendi, imzalama anahtarı: %s", len(ks.keys), ks.active.kid)
+}
+
+func loadKeySet(dir, activeKID string) (*keySet, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ks := &keySet{keys: map[string]*signingKey{}}
+	var signers []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".pem") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		var key *signingKey
+		if kid := strings.TrimSuffix(name, ".pub.pem"); kid != name {
+			key, err = parsePublicKeyPEM(kid, data)
+		} else {
+			kid = strings.TrimSuffix(name, ".pem")
+			key, err = parsePrivateKeyPEM(kid, data)
+			signers = append(signers, kid)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if _, exists := ks.keys[key.kid]; exists {
+			return nil, fmt.Errorf("%s: kid %q birden fazla kez tanımlı", name, key.kid)
+		}
+		ks.keys[key.kid] = key
+	}
+
+	if len(signers) == 0 {
+		return nil, errors.New("imzalama için özel anahtar bulunamadı")
+	}
+	if activeKID == "" {
+		sort.Strings(signers)
+		activeKID = signers[len(signers)-1]
+	}
+	active, ok := ks.keys[activeKID]
+	if !ok || active.privateKey == nil {
+		return nil, fmt.Errorf("etkin anahtar %q için özel anahtar yok", activeKID)
+	}
+	ks.active = active
+	return ks, nil
+}
+
+func parsePrivateKeyPEM(kid string, data []byte) (*signingKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("PEM bloğu okunamadı")
+	}
+
+	var parsed interface{}
+	var err error
+	if block.Type == "RSA PRIVATE KEY" {
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &signingKey{kid: kid, method: jwt.SigningMethodRS256, privateKey: k, publicKey: &k.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &signingKey{kid: kid, method: signingMethodEdDSA, privateKey: k, publicKey: k.Public()}, nil
+	}
+	return nil, fmt.Errorf("desteklenmeyen anahtar türü %T", parsed)
+}
+
+func parsePublicKeyPEM(kid string, data []byte) (*signingKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("PEM bloğu okunamadı")
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PublicKey:
+		return &signingKey{kid: kid, method: jwt.SigningMethodRS256, publicKey: k}, nil
+	case ed25519.PublicKey:
+		return &signingKey{kid: kid, method: signingMethodEdDSA, publicKey: k}, nil
+	}
+	return nil, fmt.Errorf("desteklenmeyen anahtar türü %T", parsed)
+}
+
+// sign, claim'leri etkin anahtarla imzalar ve başlığa kid ekler.
+func (ks *keySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.method, claims)
+	token.Header["kid"] = ks.active.kid
+	return token.SignedString(ks.active.privateKey)
+}
+
+// keyFunc, token başlığındaki kid'e göre doğrulama anahtarını seçer. Algoritma, anahtarın
+// türüyle eşleşmek zorundadır; böylece "alg" başlığı ile anahtar türü karıştırılamaz.
+func (ks *keySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, errUnknownKeyID
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, jwt.ErrSignatureInvalid
+	}
+	return key.publicKey, nil
+}
+
+// JWK, RFC 7517 biçimindeki tek bir açık anahtardır.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet, /.well-known/jwks.json yanıtıdır.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (ks *keySet) jwks() JWKSet {
+	kids := make([]string, 0, len(ks.keys))
+	for kid := range ks.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	set := JWKSet{Keys: []JWK{}}
+	for _, kid := range kids {
+		key := ks.keys[kid]
+		jwk := JWK{Kid: kid, Use: "sig", Alg: key.method.Alg()}
+		switch k := key.publicKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(k)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// jwksHandler, diğer servislerin token doğrulayabilmesi için açık anahtarları yayınlar.
+func jwksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(jwtKeys.jwks())
+}
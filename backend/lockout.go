@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// lockoutPolicy, art arda hatalı girişlere uygulanan cezadır. BackoffAfter hatadan sonra
+// bekleme süresi her hatada ikiye katlanır; LockAfter hataya ulaşıldığında hesap (veya IP)
+// LockDuration boyunca kilitlenir. Window'dan eski hatalar sayılmaz.
+type lockoutPolicy struct {
+	BackoffAfter int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	LockAfter    int
+	LockDuration time.Duration
+	Window       time.Duration
+}
+
+var (
+	accountLockout = lockoutPolicy{
+		BackoffAfter: 3,
+		BaseDelay:    2 * time.Second,
+		MaxDelay:     5 * time.Minute,
+		LockAfter:    10,
+		LockDuration: time.Hour,
+		Window:       24 * time.Hour,
+	}
+	// Aynı IP'den birçok hesaba denenen şifreler (password spraying) için daha geniş sınırlar
+	ipLockout = lockoutPolicy{
+		BackoffAfter: 10,
+		BaseDelay:    time.Second,
+		MaxDelay:     5 * time.Minute,
+		LockAfter:    50,
+		LockDuration: time.Hour,
+		Window:       time.Hour,
+	}
+)
+
+// delay, verilen hata sayısından sonra uygulanacak bekleme süresidir.
+func (p lockoutPolicy) delay(failures int) time.Duration {
+	if failures >= p.LockAfter {
+		return p.LockDuration
+	}
+	if failures < p.BackoffAfter {
+		return 0
+	}
+	d := p.BaseDelay
+	for i := p.BackoffAfter; i < failures && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+func accountLockoutKey(userID primitive.ObjectID) string { return "account:" + userID.Hex() }
+func ipLockoutKey(ip string) string                      { return "ip:" + ip }
+
+// delayTable, hata sayısına göre bekleme sürelerini (ms) sıralar; güncelleme pipeline'ında
+// kilit süresi bu tablodan okunur. Son eleman LockAfter ve üstü içindir.
+func (p lockoutPolicy) delayTable() bson.A {
+	table := make(bson.A, 0, p.LockAfter+1)
+	for failures := 0; failures <= p.LockAfter; failures++ {
+		table = append(table, p.delay(failures).Milliseconds())
+	}
+	return table
+}
+
+// lockedUntilExpr, pipeline içinde yeni hata sayısına göre kilidin bitiş zamanını hesaplar.
+func (p lockoutPolicy) lockedUntilExpr(now time.Time) bson.M {
+	return bson.M{"$add": bson.A{now, bson.M{"$arrayElemAt": bson.A{
+		p.delayTable(),
+		bson.M{"$min": bson.A{"$failures", p.LockAfter}},
+	}}}}
+}
+
+// reserveLoginAttempt, anahtar kilitli değilse bir denemeyi tek güncellemede ayırır: sayaç
+// artırılır ve yeni sayıya göre bekleme süresi yazılır. Şifre bu ayırmadan sonra denendiği
+// için eşzamanlı istekler kilidi birlikte atlayamaz; her istek ayrı bir sayaç değeri alır.
+// Anahtar kilitliyse hiçbir şey değiştirilmez ve kalan süre döner.
+func (s *Server) reserveLoginAttempt(ctx context.Context, key string, policy lockoutPolicy) (*LoginFailure, time.Duration, error) {
+	return s.lockouts.Reserve(ctx, key, policy, time.Now())
+}
+
+// refundLoginAttempt, başarılı bir girişin ayırdığı denemeyi geri verir ve bekleme süresini
+// kalan sayıya göre yeniden hesaplar. Süre yalnızca kısalabilir; eşzamanlı bir hatanın
+// koyduğu kilit uzatılmaz.
+func (s *Server) refundLoginAttempt(ctx context.Context, key string, policy lockoutPolicy) error {
+	return s.lockouts.Refund(ctx, key, policy, time.Now())
+}
+
+// clearLoginFailures, sayacı ve kilidi kaldırır.
+func (s *Server) clearLoginFailures(ctx context.Context, key string) error {
+	return s.lockouts.Clear(ctx, key)
+}
+
+// loginAttempt, şifre denenmeden önce IP ve (biliniyorsa) hesap için ayrılan denemedir.
+type loginAttempt struct {
+	lockouts LockoutStore
+	ipKey    string
+	account  *LoginFailure // Hesap bilinmiyorsa nil
+}
+
+// beginLoginAttempt, IP ve hesap için birer deneme ayırır. Herhangi biri kilitliyse kalan
+// süre döner ve ayrılmış olan deneme geri verilir; reddedilen istek sayaca eklenmez.
+func (s *Server) beginLoginAttempt(ctx context.Context, r *http.Request, user *User) (*loginAttempt, time.Duration, error) {
+	attempt := &loginAttempt{lockouts: s.lockouts, ipKey: ipLockoutKey(clientIP(r))}
+	_, remaining, err := s.reserveLoginAttempt(ctx, attempt.ipKey, ipLockout)
+	if err != nil || remaining > 0 {
+		return nil, remaining, err
+	}
+	if user == nil {
+		return attempt, 0, nil
+	}
+
+	record, remaining, err := s.reserveLoginAttempt(ctx, accountLockoutKey(user.ID), accountLockout)
+	if err != nil || remaining > 0 {
+		if err := s.refundLoginAttempt(ctx, attempt.ipKey, ipLockout); err != nil {
+			log.Printf("Giriş denemesi geri verilemedi: %v", err)
+		}
+		return nil, remaining, err
+	}
+	attempt.account = record
+	return attempt, 0, nil
+}
+
+// succeeded, doğru şifreden sonra hesap sayacını sıfırlar ve IP denemesini geri verir.
+func (a *loginAttempt) succeeded(ctx context.Context, user *User) {
+	if err := a.lockouts.Clear(ctx, accountLockoutKey(user.ID)); err != nil {
+		log.Printf("Giriş hatası sayacı sıfırlanamadı: %v", err)
+	}
+	if err := a.lockouts.Refund(ctx, a.ipKey, ipLockout, time.Now()); err != nil {
+		log.Printf("Giriş denemesi geri verilemedi: %v", err)
+	}
+}
+
+// handleLoginFailure, ayrılmış deneme hatalı çıktığında çağrılır. Deneme zaten sayıldığı
+// için yalnızca hesabın kilitlendiği anda kullanıcıya kilidi açma bağlantısı gönderilir.
+func (s *Server) handleLoginFailure(ctx context.Context, r *http.Request, user *User, attempt *loginAttempt) {
+	if user != nil && attempt.account != nil && attempt.account.Failures == accountLockout.LockAfter {
+		s.sendUnlockEmail(ctx, r, user)
+	}
+}
+
+// sendUnlockEmail, kilitlenen hesabın sahibine kilidi hemen açabileceği bir bağlantı gönderir.
+func (s *Server) sendUnlockEmail(ctx context.Context, r *http.Request, user *User) {
+	token, err := generateOpaqueToken()
+	if err != nil {
+		log.Printf("Kilit açma token'ı oluşturulamadı: %v", err)
+		return
+	}
+	if err := s.lockouts.SetUnlockToken(ctx, accountLockoutKey(user.ID), hashToken(token)); err != nil {
+		log.Printf("Kilit açma token'ı kaydedilemedi: %v", err)
+		return
+	}
+
+	link := publicBaseURL(r) + "/login/unlock?token=" + url.QueryEscape(token)
+	go func() {
+		body := fmt.Sprintf("Merhaba %s,\n\nHesabınıza art arda çok sayıda hatalı şifreyle giriş denendiği için hesabınız %d dakikalığına kilitlendi.\n\nBu denemeleri siz yaptıysanız aşağıdaki bağlantıyla kilidi hemen açabilirsiniz:\n\n%s\n\nSiz yapmadıysanız şifrenizi değiştirmenizi öneririz.", user.Ad, int(accountLockout.LockDuration.Minutes()), link)
+		if err := s.sendEmail(user.Email, "Hesabınız geçici olarak kilitlendi", body); err != nil {
+			log.Printf("E-posta gönderim hatası (asenkron): %v", err)
+		}
+	}()
+}
+
+// unlockAccountHandler, e-postadaki bağlantıyla hesap kilidini kaldırır. Bağlantı açıldığında
+// (GET) yalnızca onay sayfası gösterilir; kilit sayfadaki formun POST isteğiyle kaldırılır.
+func (s *Server) unlockAccountHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		writeConfirmPage(w, confirmPage{
+			Title:   "Hesap kilidini aç",
+			Message: "Hatalı denemeleri siz yaptıysanız hesabınızın kilidini şimdi açabilirsiniz.",
+			Action:  "/login/unlock",
+			Button:  "Kilidi aç",
+			Token:   r.URL.Query().Get("token"),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	token := r.PostFormValue("token")
+	if token == "" {
+		http.Error(w, "Geçersiz bağlantı", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cleared, err := s.lockouts.ClearByUnlockToken(ctx, hashToken(token))
+	if err != nil {
+		log.Printf("Kilit açma hatası: %v", err)
+		http.Error(w, "Sunucu hatası", http.StatusInternalServerError)
+		return
+	}
+	if !cleared {
+		http.Error(w, "Bağlantı geçersiz veya daha önce kullanılmış", http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "Hesabınızın kilidi açıldı, uygulamadan tekrar giriş yapabilirsiniz.")
+}
+
+// adminClearLockoutHandler, bir hesabın veya IP adresinin kilidini yönetici olarak kaldırır.
+func (s *Server) adminClearLockoutHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	var key string
+	if id := vars["id"]; id != "" {
+		userID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			http.Error(w, `{"error": "Geçersiz kullanıcı ID"}`, http.StatusBadRequest)
+			return
+		}
+		key = accountLockoutKey(userID)
+	} else {
+		key = ipLockoutKey(vars["ip"])
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := s.clearLoginFailures(ctx, key); err != nil {
+		log.Printf("Kilit kaldırma hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+	s.recordAudit(ctx, r, "lockout.clear", primitive.NilObjectID, bson.M{"key": key})
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, `{"message": "Kilit kaldırıldı"}`)
+}
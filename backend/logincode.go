@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	loginCodeTTL    = 10 * time.Minute
+	loginCodeMethod = "email-code"
+)
+
+// requestLoginCodeHandler, kayıtlı e-posta adresine tek kullanımlık bir giriş kodu gönderir.
+// Hesabın olup olmadığı yanıttan anlaşılmasın diye her durumda aynı mesaj döner.
+func (s *Server) requestLoginCodeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Yalnızca POST destekleniyor", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SendCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		http.Error(w, "Geçersiz istek gövdesi", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := s.users.FindByEmail(ctx, normalizeEmail(req.Email))
+	if err == nil && checkUserActive(user) == nil {
+		code, err := s.issueVerificationCode(ctx, CodePurposeLogin, user.Email, loginCodeTTL)
+		if _, ok := err.(*CodeCooldownError); ok {
+			// Bekleme süresi yalnızca var olan hesaplarda oluşur; yanıt bu yüzden değişmez
+		} else if err != nil {
+			log.Printf("Giriş kodu kaydetme hatası: %v", err)
+			http.Error(w, "Sunucu hatası", http.StatusInternalServerError)
+			return
+		} else {
+			body := fmt.Sprintf("Merhaba %s,\n\nGiriş kodunuz: %s\n\nBu kod 10 dakika içinde geçerliliğini yitirecektir. Giriş yapmaya çalışan siz değilseniz bu e-postayı dikkate almayın.", user.Ad, code)
+			if err := s.sendEmail(user.Email, "Giriş Kodunuz", body); err != nil {
+				log.Printf("E-posta gönderme hatası: %v", err)
+				http.Error(w, "E-posta gönderme başarısız", http.StatusInternalServerError)
+				return
+			}
+		}
+	} else if err != nil && err != errNotFound {
+		log.Printf("Veritabanı hatası: %v", err)
+		http.Error(w, "Sunucu hatası", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MessageResponse{Message: "Bu adrese kayıtlı bir hesap varsa giriş kodu gönderildi"})
+}
+
+// loginWithCodeHandler, e-postayla gönderilen giriş kodunu tüketir ve token ikilisini verir.
+// Hatalı kodlar şifre denemeleri gibi kilit sayacına eklenir.
+func (s *Server) loginWithCodeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Yalnızca POST destekleniyor", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req LoginCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" || req.Code == "" {
+		http.Error(w, "Geçersiz istek gövdesi", http.StatusBadRequest)
+		return
+	}
+	email := normalizeEmail(req.Email)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := s.users.FindByEmail(ctx, email)
+	if err != nil && err != errNotFound {
+		log.Printf("Veritabanı hatası: %v", err)
+		http.Error(w, "Sunucu hatası", http.StatusInternalServerError)
+		return
+	}
+
+	attempt, remaining, lockErr := s.beginLoginAttempt(ctx, r, user)
+	if lockErr != nil {
+		log.Printf("Kilit durumu okunamadı: %v", lockErr)
+		http.Error(w, "Sunucu hatası", http.StatusInternalServerError)
+		return
+	}
+	if remaining > 0 {
+		if err == nil {
+			s.recordLoginEvent(r, user, email, loginCodeMethod, false, "locked")
+		}
+		writeRetryAfter(w, remaining)
+		http.Error(w, "Çok fazla hatalı deneme yapıldı, lütfen daha sonra tekrar deneyin", http.StatusTooManyRequests)
+		return
+	}
+
+	if err == errNotFound {
+		s.recordLoginEvent(r, nil, email, loginCodeMethod, false, "unknown_user")
+		s.handleLoginFailure(ctx, r, nil, attempt)
+		http.Error(w, "Geçersiz veya süresi dolmuş kod", http.StatusUnauthorized)
+		return
+	}
+
+	err = s.consumeVerificationCode(ctx, CodePurposeLogin, user.Email, req.Code)
+	if err == errCodeTooManyAttempts {
+		s.recordLoginEvent(r, user, email, loginCodeMethod, false, "wrong_code")
+		s.handleLoginFailure(ctx, r, user, attempt)
+		http.Error(w, "Çok fazla hatalı deneme, lütfen yeni kod isteyin", http.StatusTooManyRequests)
+		return
+	} else if err == errCodeInvalid {
+		s.recordLoginEvent(r, user, email, loginCodeMethod, false, "wrong_code")
+		s.handleLoginFailure(ctx, r, user, attempt)
+		http.Error(w, "Geçersiz veya süresi dolmuş kod", http.StatusUnauthorized)
+		return
+	} else if err != nil {
+		log.Printf("Veritabanı hatası: %v", err)
+		http.Error(w, "Sunucu hatası", http.StatusInternalServerError)
+		return
+	}
+	attempt.succeeded(ctx, user)
+	switch checkUserActive(user) {
+	case errAccountDeleted:
+		s.recordLoginEvent(r, user, email, loginCodeMethod, false, "deleted")
+		http.Error(w, "Bu hesap silinme sürecinde", http.StatusForbidden)
+		return
+	case errAccountBanned:
+		s.recordLoginEvent(r, user, email, loginCodeMethod, false, "banned")
+		http.Error(w, "Hesabınız askıya alınmış", http.StatusForbidden)
+		return
+	}
+
+	if user.TOTPEnabled {
+		challenge, err := createPurposeToken(user, "2fa", twoFactorChallengeTTL)
+		if err != nil {
+			log.Printf("Token oluşturma hatası: %v", err)
+			http.Error(w, "Token oluşturulamadı", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(TwoFactorLoginResponse{
+			Status:         "2fa_required",
+			Message:        "İki adımlı doğrulama kodu gerekli",
+			ChallengeToken: challenge,
+		})
+		return
+	}
+
+	pair, err := s.issueLoginTokens(ctx, user, r, loginCodeMethod)
+	if err != nil {
+		log.Printf("Token oluşturma hatası: %v", err)
+		http.Error(w, "Token oluşturulamadı", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(TokenResponse{
+		Status:       "success",
+		Message:      "Giriş başarılı",
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
+	})
+}
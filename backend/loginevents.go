@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	loginHistoryDefaultLimit = 50
+	loginHistoryMaxLimit     = 200
+)
+
+// deviceFingerprint, cihazı IP'den bağımsız olarak tanımlar; mobil ağlarda IP sık değiştiği
+// için yalnızca uygulamanın gönderdiği X-Device-Id ve user agent kullanılır.
+func deviceFingerprint(r *http.Request) string {
+	return hashToken(r.Header.Get("X-Device-Id") + "|" + r.UserAgent())[:32]
+}
+
+// inactiveReason, checkUserActive hatasını giriş kaydındaki nedene çevirir.
+func inactiveReason(err error) string {
+	if err == errAccountDeleted {
+		return "deleted"
+	}
+	return "banned"
+}
+
+// recordLoginEvent, giriş denemesini arka planda kaydeder. Başarılı bir girişin cihazı bu
+// kullanıcı için yeniyse güvenlik uyarısı e-postası gönderilir. Kayıt hataları girişi engellemez.
+func (s *Server) recordLoginEvent(r *http.Request, user *User, email, method string, success bool, reason string) {
+	event := LoginEvent{
+		Email:     email,
+		Method:    method,
+		Success:   success,
+		Reason:    reason,
+		IP:        clientIP(r),
+		UserAgent: r.UserAgent(),
+		DeviceID:  deviceFingerprint(r),
+		CreatedAt: time.Now(),
+	}
+	if user != nil {
+		event.UserID = user.ID
+		event.Email = user.Email
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		alert := false
+		// Token yenileme aynı cihazdan gelir; yeni cihaz kontrolü yalnızca gerçek girişlerde yapılır
+		if success && user != nil && method != "refresh" {
+			known, err := s.loginEvents.HasSuccess(ctx, user.ID, event.DeviceID)
+			if err != nil {
+				log.Printf("Giriş geçmişi okuma hatası: %v", err)
+			} else if !known {
+				event.NewDevice = true
+				// Hesabın ilk girişinde uyarı gönderilmez
+				previous, err := s.loginEvents.HasSuccess(ctx, user.ID, "")
+				alert = err == nil && previous
+			}
+		}
+
+		if err := s.loginEvents.Insert(ctx, &event); err != nil {
+			log.Printf("Giriş geçmişi kaydetme hatası: %v", err)
+		}
+		if alert {
+			s.sendNewDeviceAlert(user, &event)
+		}
+	}()
+}
+
+// sendNewDeviceAlert, daha önce görülmemiş bir cihazdan yapılan girişi kullanıcıya bildirir.
+func (s *Server) sendNewDeviceAlert(user *User, event *LoginEvent) {
+	body := fmt.Sprintf("Merhaba %s,\n\nHesabınıza yeni bir cihazdan giriş yapıldı.\n\nZaman: %s\nYöntem: %s\nIP adresi: %s\nCihaz: %s\n\nBu giriş size ait değilse lütfen şifrenizi değiştirin ve tüm cihazlardan çıkış yapın.",
+		user.Ad, event.CreatedAt.Format("02.01.2006 15:04"), event.Method, event.IP, event.UserAgent)
+	if err := s.sendEmail(user.Email, "Hesabınıza yeni bir cihazdan giriş yapıldı", body); err != nil {
+		log.Printf("E-posta gönderim hatası (asenkron): %v", err)
+	}
+}
+
+// issueLoginTokens, tamamlanmış bir giriş için token ikilisini verir ve girişi geçmişe kaydeder.
+func (s *Server) issueLoginTokens(ctx context.Context, user *User, r *http.Request, method string) (*TokenPair, error) {
+	pair, err := s.issueTokenPair(ctx, user, r)
+	if err != nil {
+		return nil, err
+	}
+	s.recordLoginEvent(r, user, user.Email, method, true, "")
+	return pair, nil
+}
+
+// loginHistoryHandler, kullanıcının son giriş denemelerini yeniden eskiye döndürür.
+func (s *Server) loginHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims, ok := s.authenticateRequest(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := s.getUserByClaims(claims)
+	if err != nil {
+		http.Error(w, `{"error": "Kullanıcı bulunamadı"}`, http.StatusNotFound)
+		return
+	}
+
+	limit := loginHistoryDefaultLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, `{"error": "Geçersiz limit"}`, http.StatusBadRequest)
+			return
+		}
+		if n > loginHistoryMaxLimit {
+			n = loginHistoryMaxLimit
+		}
+		limit = n
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	events, err := s.loginEvents.ListByUser(ctx, user.ID, limit)
+	if err != nil {
+		log.Printf("Giriş geçmişi okuma hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"logins": events})
+}
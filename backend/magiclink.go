@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	magicLinkTTL     = 15 * time.Minute
+	magicLinkPurpose = "magic-link"
+)
+
+var errMagicLinkInvalid = errors.New("giriş bağlantısı geçersiz, kullanılmış veya süresi dolmuş")
+
+// requestMagicLinkHandler, kayıtlı e-posta adresine tek kullanımlık bir giriş bağlantısı gönderir.
+// Hesabın olup olmadığı yanıttan anlaşılmasın diye her durumda aynı mesaj döner.
+func (s *Server) requestMagicLinkHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Yalnızca POST destekleniyor", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req MagicLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		http.Error(w, "Geçersiz istek gövdesi", http.StatusBadRequest)
+		return
+	}
+
+	redirectURI, err := resolveLoginRedirect(req.RedirectURI)
+	if err != nil {
+		http.Error(w, "Yönlendirme adresine izin verilmiyor", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := s.users.FindByEmail(ctx, normalizeEmail(req.Email))
+	if err == nil {
+		if err := s.sendMagicLink(ctx, r, user, redirectURI); err != nil {
+			log.Printf("Giriş bağlantısı gönderme hatası: %v", err)
+			http.Error(w, "Sunucu hatası", http.StatusInternalServerError)
+			return
+		}
+	} else if err != errNotFound {
+		log.Printf("Veritabanı hatası: %v", err)
+		http.Error(w, "Sunucu hatası", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MessageResponse{Message: "Bu adrese kayıtlı bir hesap varsa giriş bağlantısı gönderildi"})
+}
+
+// sendMagicLink, imzalı bağlantı token'ını oluşturur, tek kullanımlık kaydını yazar ve e-postayı gönderir.
+func (s *Server) sendMagicLink(ctx context.Context, r *http.Request, user *User, redirectURI string) error {
+	token, err := createPurposeToken(user, magicLinkPurpose, magicLinkTTL)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	_, err = magicLinksCollection.InsertOne(ctx, MagicLink{
+		TokenHash:   hashToken(token),
+		UserID:      user.ID,
+		RedirectURI: redirectURI,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(magicLinkTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := publicBaseURL(r) + "/login/magic-link/verify?token=" + url.QueryEscape(token)
+	go func() {
+		body := fmt.Sprintf("Merhaba %s,\n\nEventra'ya giriş yapmak için aşağıdaki bağlantıya dokunun. Bağlantı %d dakika geçerlidir ve yalnızca bir kez kullanılabilir:\n\n%s\n\nBu isteği siz yapmadıysanız bu e-postayı yok sayabilirsiniz.", user.Ad, int(magicLinkTTL.Minutes()), link)
+		if err := s.sendEmail(user.Email, "Eventra giriş bağlantınız", body); err != nil {
+			log.Printf("E-posta gönderim hatası (asenkron): %v", err)
+		}
+	}()
+	return nil
+}
+
+// consumeMagicLink, token'ın imzasını ve süresini doğrular, ardından kaydı atomik olarak
+// kullanılmış işaretler. Aynı bağlantı ikinci kez açıldığında errMagicLinkInvalid döner.
+func (s *Server) consumeMagicLink(ctx context.Context, token string) (*User, *MagicLink, error) {
+	claims, err := s.parsePurposeToken(token, magicLinkPurpose)
+	if err != nil {
+		return nil, nil, errMagicLinkInvalid
+	}
+
+	now := time.Now()
+	var link MagicLink
+	err = magicLinksCollection.FindOneAndUpdate(ctx,
+		bson.M{
+			"tokenHash": hashToken(token),
+			"usedAt":    bson.M{"$exists": false},
+			"expiresAt": bson.M{"$gt": now},
+		},
+		bson.M{"$set": bson.M{"usedAt": now}},
+	).Decode(&link)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil, errMagicLinkInvalid
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.users.FindByID(ctx, link.UserID)
+	if err == errNotFound || (err == nil && user.ID.Hex() != claims.Subject) {
+		return nil, nil, errMagicLinkInvalid
+	} else if err != nil {
+		return nil, nil, err
+	}
+	return user, &link, nil
+}
+
+// verifyMagicLinkHandler, bağlantı tarayıcıda açıldığında (GET) token'ı tüketmeden onay
+// sayfasını gösterir. Sayfadaki form POST ile döndüğünde giriş tamamlanıp uygulamaya
+// yönlendirilir; uygulama token'ı JSON ile gönderdiğinde JSON döner. 2FA açık hesaplarda
+// bağlantı şifrenin yerini alır, ikinci adım yine /login/2fa ile tamamlanır.
+func (s *Server) verifyMagicLinkHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		s.showMagicLinkConfirm(w, r)
+		return
+	}
+
+	browser := strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded")
+	var token string
+	if browser {
+		token = r.PostFormValue("token")
+	} else {
+		var req MagicLinkVerifyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Geçersiz istek gövdesi", http.StatusBadRequest)
+			return
+		}
+		token = req.Token
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, link, err := s.consumeMagicLink(ctx, token)
+	if err == errMagicLinkInvalid {
+		if browser {
+			redirectWithError(w, r, defaultLoginRedirect, "magic_link_invalid")
+			return
+		}
+		http.Error(w, "Giriş bağlantısı geçersiz, kullanılmış veya süresi dolmuş", http.StatusUnauthorized)
+		return
+	} else if err != nil {
+		log.Printf("Giriş bağlantısı doğrulama hatası: %v", err)
+		http.Error(w, "Sunucu hatası", http.StatusInternalServerError)
+		return
+	}
+
+	if user.TOTPEnabled {
+		challenge, err := createPurposeToken(user, "2fa", twoFactorChallengeTTL)
+		if err != nil {
+			log.Printf("Token oluşturma hatası: %v", err)
+			http.Error(w, "Token oluşturulamadı", http.StatusInternalServerError)
+			return
+		}
+		if browser {
+			redirectToApp(w, r, link.RedirectURI, map[string]string{
+				"challengeToken": challenge,
+				"type":           "2fa_required",
+			})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(TwoFactorLoginResponse{
+			Status:         "2fa_required",
+			Message:        "İki adımlı doğrulama kodu gerekli",
+			ChallengeToken: challenge,
+		})
+		return
+	}
+
+	pair, err := s.issueLoginTokens(ctx, user, r, magicLinkPurpose)
+	if err != nil {
+		log.Printf("Token oluşturma hatası: %v", err)
+		http.Error(w, "Token oluşturulamadı", http.StatusInternalServerError)
+		return
+	}
+
+	if browser {
+		redirectToApp(w, r, link.RedirectURI, map[string]string{
+			"token":        pair.AccessToken,
+			"refreshToken": pair.RefreshToken,
+			"type":         magicLinkPurpose,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(TokenResponse{
+		Status:       "success",
+		Message:      "Giriş başarılı",
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
+	})
+}
+
+// showMagicLinkConfirm, onay sayfasını yazar. Token burada yalnızca imza ve süre açısından
+// denetlenir; geçersizse sayfa gösterilmeden uygulamaya hata ile dönülür.
+func (s *Server) showMagicLinkConfirm(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if _, err := s.parsePurposeToken(token, magicLinkPurpose); err != nil {
+		redirectWithError(w, r, defaultLoginRedirect, "magic_link_invalid")
+		return
+	}
+
+	writeConfirmPage(w, confirmPage{
+		Title:   "Eventra'ya giriş",
+		Message: "Giriş yapmak için aşağıdaki düğmeye dokunun.",
+		Action:  "/login/magic-link/verify",
+		Button:  "Giriş yap",
+		Token:   token,
+	})
+}
+
+// redirectToApp, tarayıcı akışının sonucunu derin bağlantı parametreleriyle uygulamaya iletir.
+func redirectToApp(w http.ResponseWriter, r *http.Request, base string, params map[string]string) {
+	redirectURL, err := buildLoginRedirect(base, params)
+	if err != nil {
+		http.Error(w, "Yönlendirme adresi oluşturulamadı", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+}
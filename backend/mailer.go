@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errMailNotFound     = errors.New("e-posta bulunamadı")
+	errInvalidRecipient = errors.New("geçersiz alıcı adresi")
+)
+
+// Message, gönderilecek düz metin e-postadır.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// SentMessage, dosyaya veya belleğe yazılmış bir e-postadır; önizleme ve testlerde okunur.
+type SentMessage struct {
+	ID      string    `json:"id"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body"`
+	SentAt  time.Time `json:"sentAt"`
+}
+
+// Mailer, e-postaları gönderen arka uçtur.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// MailPreviewer, gönderilen e-postaları geri okuyabilen arka uçlardır (dosya ve bellek).
+// Geliştirici önizleme sayfası yalnızca bu arka uçlarda ve MAIL_PREVIEW=1 iken açılır.
+type MailPreviewer interface {
+	List() ([]SentMessage, error)
+	Get(id string) (*SentMessage, error)
+}
+
+// InitMailer, MAIL_BACKEND değişkenine göre arka ucu seçer: "smtp" (varsayılan) gerçek
+// e-posta gönderir, "file" her e-postayı MAIL_DIR altına .eml dosyası olarak yazar,
+// "memory" e-postaları süreç içinde tutar.
+func InitMailer() Mailer {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = os.Getenv("SMTP_USER")
+	}
+
+	switch os.Getenv("MAIL_BACKEND") {
+	case "", "smtp":
+		mailer := &smtpMailer{
+			host:     os.Getenv("SMTP_HOST"),
+			port:     os.Getenv("SMTP_PORT"),
+			username: os.Getenv("SMTP_USER"),
+			password: os.Getenv("SMTP_PASSWORD"),
+			from:     from,
+		}
+		if mailer.host == "" {
+			log.Printf("Uyarı: SMTP_HOST tanımlı değil, e-postalar gönderilemeyecek. Yerel geliştirmede MAIL_BACKEND=file kullanın.")
+		}
+		return mailer
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "mail-outbox"
+		}
+		mailer, err := newFileMailer(dir, from)
+		if err != nil {
+			log.Fatal("E-posta klasörü oluşturulamadı: ", err)
+		}
+		log.Printf("E-postalar gönderilmeyecek, %s klasörüne yazılacak", dir)
+		return mailer
+	case "memory":
+		log.Printf("E-postalar gönderilmeyecek, bellekte tutulacak")
+		return NewMemoryMailer(from)
+	default:
+		log.Fatal("Geçersiz MAIL_BACKEND değeri: ", os.Getenv("MAIL_BACKEND"))
+		return nil
+	}
+}
+
+// newMessageID, e-posta ve dosya adı olarak kullanılan, zamana göre sıralanabilen bir kimliktir.
+func newMessageID(now time.Time) (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return now.UTC().Format("20060102T150405.000000") + "-" + hex.EncodeToString(b), nil
+}
+
+// recipientAddress, alıcıyı tek bir e-posta adresi olarak ayrıştırır. Adres başlığa yazıldığı
+// için satır sonu içeren değerler başlık eklenmesine yol açmasın diye reddedilir.
+func recipientAddress(to string) (string, error) {
+	if strings.ContainsAny(to, "\r\n") {
+		return "", errInvalidRecipient
+	}
+	addr, err := mail.ParseAddress(to)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errInvalidRecipient, err)
+	}
+	return addr.Address, nil
+}
+
+// buildMessage, mesajı RFC 5322 biçiminde yazar. Konu ve gövde UTF-8 olduğu için konu
+// MIME ile, gövde quoted-printable ile kodlanır.
+func buildMessage(id, from string, msg Message, now time.Time) ([]byte, error) {
+	to, err := recipientAddress(msg.To)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if from != "" {
+		fmt.Fprintf(&buf, "From: %s\r\n", from)
+	}
+	fmt.Fprintf(&buf, "To: %s\r\n", to)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@eventra>\r\n", id)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+	buf.WriteString("\r\n")
+
+	qp := quotedprintable.NewWriter(&buf)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(msg.Body, "\n", "\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\r\n")
+	return buf.Bytes(), nil
+}
+
+// parseMessage, buildMessage ile yazılmış bir .eml dosyasını okur.
+func parseMessage(id string, raw []byte) (*SentMessage, error) {
+	parsed, err := mail.ReadMessage(bufio.NewReader(bytes.NewReader(raw)))
+	if err != nil {
+		return nil, err
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil {
+		return nil, err
+	}
+	var body io.Reader = parsed.Body
+	if strings.EqualFold(parsed.Header.Get("Content-Transfer-Encoding"), "quoted-printable") {
+		body = quotedprintable.NewReader(body)
+	}
+	text, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	sentAt, _ := parsed.Header.Date()
+	return &SentMessage{
+		ID:      id,
+		From:    parsed.Header.Get("From"),
+		To:      parsed.Header.Get("To"),
+		Subject: subject,
+		Body:    strings.TrimRight(strings.ReplaceAll(string(text), "\r\n", "\n"), "\n"),
+		SentAt:  sentAt,
+	}, nil
+}
+
+// smtpMailer, e-postaları SMTP sunucusu üzerinden gönderir.
+type smtpMailer struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+func (m *smtpMailer) Send(msg Message) error {
+	now := time.Now()
+	id, err := newMessageID(now)
+	if err != nil {
+		return err
+	}
+	raw, err := buildMessage(id, m.from, msg, now)
+	if err != nil {
+		return err
+	}
+	to, err := recipientAddress(msg.To)
+	if err != nil {
+		return err
+	}
+	auth := smtp.PlainAuth("", m.username, m.password, m.host)
+	return smtp.SendMail(m.host+":"+m.port, auth, m.from, []string{to}, raw)
+}
+
+// fileMailer, e-postaları göndermek yerine klasöre .eml dosyası olarak yazar. Dosyalar
+// herhangi bir e-posta istemcisiyle açılabilir.
+type fileMailer struct {
+	dir  string
+	from string
+}
+
+func newFileMailer(dir, from string) (*fileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &fileMailer{dir: dir, from: from}, nil
+}
+
+func (m *fileMailer) Send(msg Message) error {
+	now := time.Now()
+	id, err := newMessageID(now)
+	if err != nil {
+		return err
+	}
+	raw, err := buildMessage(id, m.from, msg, now)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(m.dir, id+".eml"), raw, 0o644)
+}
+
+func (m *fileMailer) List() ([]SentMessage, error) {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return nil, err
+	}
+	var messages []SentMessage
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".eml") {
+			continue
+		}
+		msg, err := m.Get(strings.TrimSuffix(entry.Name(), ".eml"))
+		if err != nil {
+			log.Printf("E-posta dosyası okunamadı (%s): %v", entry.Name(), err)
+			continue
+		}
+		messages = append(messages, *msg)
+	}
+	// Kimlik zamanla başladığı için ters sıralama yeniden eskiye sıralar
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID > messages[j].ID })
+	return messages, nil
+}
+
+func (m *fileMailer) Get(id string) (*SentMessage, error) {
+	// Kimlik dosya adına dönüştüğü için klasör dışına çıkan değerler reddedilir
+	if id == "" || filepath.Base(id) != id || strings.HasPrefix(id, ".") {
+		return nil, errMailNotFound
+	}
+	raw, err := os.ReadFile(filepath.Join(m.dir, id+".eml"))
+	if os.IsNotExist(err) {
+		return nil, errMailNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return parseMessage(id, raw)
+}
+
+// MemoryMailer, e-postaları süreç içinde tutar. Testler gönderilen e-postaları Messages ve
+// Last ile okuyabilir.
+type MemoryMailer struct {
+	mu       sync.Mutex
+	from     string
+	messages []SentMessage
+}
+
+func NewMemoryMailer(from string) *MemoryMailer {
+	return &MemoryMailer{from: from}
+}
+
+func (m *MemoryMailer) Send(msg Message) error {
+	to, err := recipientAddress(msg.To)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	id, err := newMessageID(now)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, SentMessage{
+		ID:      id,
+		From:    m.from,
+		To:      to,
+		Subject: msg.Subject,
+		Body:    msg.Body,
+		SentAt:  now,
+	})
+	return nil
+}
+
+// Messages, gönderilen e-postaları gönderim sırasıyla döndürür.
+func (m *MemoryMailer) Messages() []SentMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]SentMessage(nil), m.messages...)
+}
+
+// Last, adrese gönderilen son e-postayı döndürür.
+func (m *MemoryMailer) Last(to string) (*SentMessage, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To == to {
+			msg := m.messages[i]
+			return &msg, true
+		}
+	}
+	return nil, false
+}
+
+// Reset, kutuyu boşaltır.
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = nil
+}
+
+func (m *MemoryMailer) List() ([]SentMessage, error) {
+	messages := m.Messages()
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+func (m *MemoryMailer) Get(id string) (*SentMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, msg := range m.messages {
+		if msg.ID == id {
+			msg := msg
+			return &msg, nil
+		}
+	}
+	return nil, errMailNotFound
+}
+
+// sendEmail, düz metin bir e-postayı yapılandırılmış arka uçla gönderir.
+func (s *Server) sendEmail(to, subject, body string) error {
+	return s.mailer.Send(Message{To: to, Subject: subject, Body: body})
+}
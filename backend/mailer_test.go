@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryMailer(t *testing.T) {
+	mailer := NewMemoryMailer("eventra@example.com")
+	if err := mailer.Send(Message{To: "ayse@example.com", Subject: "İlk", Body: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mailer.Send(Message{To: "mehmet@example.com", Subject: "İkinci", Body: "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mailer.Send(Message{To: "ayse@example.com", Subject: "Üçüncü", Body: "3"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(mailer.Messages()); n != 3 {
+		t.Fatalf("%d e-posta, beklenen 3", n)
+	}
+	last, ok := mailer.Last("ayse@example.com")
+	if !ok || last.Subject != "Üçüncü" || last.From != "eventra@example.com" {
+		t.Errorf("Last = %+v", last)
+	}
+	if _, ok := mailer.Last("yok@example.com"); ok {
+		t.Error("gönderilmeyen adres için e-posta bulundu")
+	}
+
+	list, _ := mailer.List()
+	if list[0].Subject != "Üçüncü" || list[2].Subject != "İlk" {
+		t.Errorf("List yeniden eskiye sıralı değil: %v, %v", list[0].Subject, list[2].Subject)
+	}
+	got, err := mailer.Get(list[1].ID)
+	if err != nil || got.Subject != "İkinci" {
+		t.Errorf("Get = %+v, %v", got, err)
+	}
+	if _, err := mailer.Get("yok"); err != errMailNotFound {
+		t.Errorf("bilinmeyen kimlik: %v", err)
+	}
+
+	mailer.Reset()
+	if n := len(mailer.Messages()); n != 0 {
+		t.Errorf("Reset sonrası %d e-posta kaldı", n)
+	}
+}
+
+func TestBuildParseMessageRoundTrip(t *testing.T) {
+	now := time.Date(2026, 3, 14, 9, 26, 53, 0, time.UTC)
+	msg := Message{
+		To:      "zeynep@example.com",
+		Subject: "Şifre sıfırlama kodunuz — Eventra",
+		// Uzun satır quoted-printable ile bölünür; "=" ve Türkçe karakterler kodlanır
+		Body: "Merhaba Zeynep,\n\nKodunuz: 123456 (a=b)\n\n" + strings.Repeat("çok uzun bir satır ", 10) + "\nİyi günler.",
+	}
+
+	raw, err := buildMessage("20260314T092653.000000-abc", "Eventra <eventra@example.com>", msg, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(raw, []byte("Subject: =?utf-8?q?")) {
+		t.Error("konu MIME ile kodlanmadı")
+	}
+	if !bytes.Contains(raw, []byte("Content-Transfer-Encoding: quoted-printable")) {
+		t.Error("gövde quoted-printable değil")
+	}
+	for _, line := range bytes.Split(raw, []byte("\r\n")) {
+		if len(line) > 78 {
+			t.Errorf("satır 78 karakterden uzun: %q", line)
+		}
+	}
+
+	parsed, err := parseMessage("20260314T092653.000000-abc", raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.To != msg.To || parsed.Subject != msg.Subject || parsed.Body != msg.Body {
+		t.Errorf("gidiş-dönüş farklı:\n%+v\n%+v", msg, parsed)
+	}
+	if parsed.From != "Eventra <eventra@example.com>" || !parsed.SentAt.Equal(now) {
+		t.Errorf("From/Date = %q, %v", parsed.From, parsed.SentAt)
+	}
+}
+
+func TestBuildMessageRejectsHeaderInjection(t *testing.T) {
+	for _, to := range []string{
+		"ayse@example.com\r\nBcc: herkes@example.com",
+		"ayse@example.com\nBcc: herkes@example.com",
+		"ayse@example.com, mehmet@example.com",
+		"geçersiz",
+		"",
+	} {
+		_, err := buildMessage("id", "", Message{To: to, Subject: "Konu", Body: "Gövde"}, time.Now())
+		if !errors.Is(err, errInvalidRecipient) {
+			t.Errorf("To %q: %v, beklenen errInvalidRecipient", to, err)
+		}
+	}
+}
+
+func TestFileMailerRejectsPathTraversal(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "outbox")
+	mailer, err := newFileMailer(dir, "eventra@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Klasör dışında, kimlikle ulaşılmaya çalışılan bir .eml dosyası
+	secret, err := buildMessage("secret", "", Message{To: "gizli@example.com", Subject: "Gizli", Body: "gizli"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "secret.eml"), secret, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"../secret", "..", ".hidden", "a/../../secret", filepath.Join(root, "secret"), ""} {
+		if _, err := mailer.Get(id); err != errMailNotFound {
+			t.Errorf("Get(%q) = %v, beklenen errMailNotFound", id, err)
+		}
+	}
+
+	if err := mailer.Send(Message{To: "ayse@example.com", Subject: "Merhaba", Body: "Gövde"}); err != nil {
+		t.Fatal(err)
+	}
+	list, err := mailer.List()
+	if err != nil || len(list) != 1 {
+		t.Fatalf("List = %d e-posta, %v", len(list), err)
+	}
+	got, err := mailer.Get(list[0].ID)
+	if err != nil || got.Subject != "Merhaba" || got.To != "ayse@example.com" {
+		t.Errorf("Get = %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+var mailListTemplate = template.Must(template.New("list").Parse(`<!DOCTYPE html>
+<html lang="tr"><head><meta charset="utf-8"><title>Giden e-postalar</title>
+<style>body{font-family:sans-serif;margin:2em}td,th{padding:4px 12px;text-align:left}</style></head>
+<body><h1>Giden e-postalar</h1>
+{{if .}}<table><tr><th>Zaman</th><th>Alıcı</th><th>Konu</th></tr>
+{{range .}}<tr><td>{{.SentAt.Format "02.01.2006 15:04:05"}}</td><td>{{.To}}</td><td><a href="/dev/mail/{{.ID}}">{{.Subject}}</a></td></tr>
+{{end}}</table>{{else}}<p>Henüz e-posta gönderilmedi.</p>{{end}}
+</body></html>`))
+
+var mailMessageTemplate = template.Must(template.New("message").Parse(`<!DOCTYPE html>
+<html lang="tr"><head><meta charset="utf-8"><title>{{.Subject}}</title>
+<style>body{font-family:sans-serif;margin:2em}pre{white-space:pre-wrap;background:#f4f4f4;padding:1em}</style></head>
+<body><p><a href="/dev/mail">&larr; Tüm e-postalar</a></p>
+<h1>{{.Subject}}</h1>
+<p><b>Kimden:</b> {{.From}}<br><b>Kime:</b> {{.To}}<br><b>Zaman:</b> {{.SentAt.Format "02.01.2006 15:04:05"}}</p>
+<pre>{{.Body}}</pre>
+</body></html>`))
+
+const mailPreviewPrefix = "/dev/mail"
+
+// withMailPreview, gönderilen e-postaları tarayıcıda gösteren geliştirici sayfalarını API'nin
+// önüne ekler. Sayfalar doğrulama kodlarını ve giriş bağlantılarını gösterdiği için yalnızca
+// MAIL_PREVIEW=1 verildiğinde ve e-postaların gerçekten gönderilmediği "file" veya "memory"
+// arka uçlarında açılır. next'i saran CORS katmanının dışında kalır ve yalnızca aynı
+// makineden gelen isteklere yanıt verir.
+func withMailPreview(next http.Handler, mailer Mailer) http.Handler {
+	if os.Getenv("MAIL_PREVIEW") != "1" {
+		return next
+	}
+	previewer, ok := mailer.(MailPreviewer)
+	if !ok {
+		log.Printf("Uyarı: MAIL_PREVIEW yalnızca MAIL_BACKEND=file veya memory ile kullanılabilir")
+		return next
+	}
+
+	preview := mux.NewRouter()
+	preview.HandleFunc(mailPreviewPrefix, mailListHandler(previewer)).Methods("GET")
+	preview.HandleFunc(mailPreviewPrefix+"/{id}", mailMessageHandler(previewer)).Methods("GET")
+	log.Printf("E-posta önizleme sayfası: %s (yalnızca localhost)", mailPreviewPrefix)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != mailPreviewPrefix && !strings.HasPrefix(r.URL.Path, mailPreviewPrefix+"/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !isLoopbackRequest(r) {
+			http.NotFound(w, r)
+			return
+		}
+		preview.ServeHTTP(w, r)
+	})
+}
+
+// isLoopbackRequest, isteğin doğrudan aynı makineden geldiğini denetler. Proxy üzerinden
+// gelen istekler (yönlendirme başlığı taşıyanlar) yerel sayılmaz.
+func isLoopbackRequest(r *http.Request) bool {
+	for _, header := range []string{"X-Forwarded-For", "X-Real-IP", "Forwarded"} {
+		if r.Header.Get(header) != "" {
+			return false
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// mailListHandler, gönderilen e-postaları yeniden eskiye listeler.
+func mailListHandler(previewer MailPreviewer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		messages, err := previewer.List()
+		if err != nil {
+			log.Printf("E-posta listesi okunamadı: %v", err)
+			http.Error(w, "Sunucu hatası", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := mailListTemplate.Execute(w, messages); err != nil {
+			log.Printf("E-posta listesi yazılamadı: %v", err)
+		}
+	}
+}
+
+// mailMessageHandler, tek bir e-postayı gösterir.
+func mailMessageHandler(previewer MailPreviewer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		msg, err := previewer.Get(mux.Vars(r)["id"])
+		if err == errMailNotFound {
+			http.Error(w, "E-posta bulunamadı", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Printf("E-posta okunamadı: %v", err)
+			http.Error(w, "Sunucu hatası", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := mailMessageTemplate.Execute(w, msg); err != nil {
+			log.Printf("E-posta yazılamadı: %v", err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func servePreview(handler http.Handler, remoteAddr string, header http.Header) int {
+	req := httptest.NewRequest(http.MethodGet, "/dev/mail", nil)
+	req.RemoteAddr = remoteAddr
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestMailPreviewRequiresFlag(t *testing.T) {
+	api := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { http.NotFound(w, r) })
+
+	t.Setenv("MAIL_PREVIEW", "")
+	if code := servePreview(withMailPreview(api, NewMemoryMailer("")), "127.0.0.1:5000", nil); code != http.StatusNotFound {
+		t.Errorf("bayrak olmadan: %d, beklenen 404", code)
+	}
+
+	// SMTP arka ucunda bayrak verilse de önizleme açılmaz
+	t.Setenv("MAIL_PREVIEW", "1")
+	if code := servePreview(withMailPreview(api, &smtpMailer{}), "127.0.0.1:5000", nil); code != http.StatusNotFound {
+		t.Errorf("smtp arka ucu: %d, beklenen 404", code)
+	}
+}
+
+func TestMailPreviewOnlyServesLoopback(t *testing.T) {
+	t.Setenv("MAIL_PREVIEW", "1")
+	apiCalled := false
+	api := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { apiCalled = true })
+	handler := withMailPreview(api, NewMemoryMailer(""))
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		want       int
+	}{
+		{"IPv4 loopback", "127.0.0.1:5000", nil, http.StatusOK},
+		{"IPv6 loopback", "[::1]:5000", nil, http.StatusOK},
+		{"uzak adres", "203.0.113.7:5000", nil, http.StatusNotFound},
+		{"proxy üzerinden", "127.0.0.1:5000", http.Header{"X-Forwarded-For": {"203.0.113.7"}}, http.StatusNotFound},
+		{"X-Real-IP", "127.0.0.1:5000", http.Header{"X-Real-Ip": {"203.0.113.7"}}, http.StatusNotFound},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if code := servePreview(handler, tc.remoteAddr, tc.header); code != tc.want {
+				t.Errorf("durum = %d, beklenen %d", code, tc.want)
+			}
+		})
+	}
+	if apiCalled {
+		t.Error("önizleme istekleri API'ye (CORS katmanına) iletildi")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if !apiCalled {
+		t.Error("diğer istekler API'ye iletilmedi")
+	}
+}
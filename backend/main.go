@@ -10,27 +10,95 @@ import (
 )
 
 func main() {
+	// "backend migrate ..." sunucuyu başlatmadan yalnızca şema adımlarını çalıştırır
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrateCommand(os.Args[2:])
+		return
+	}
+
 	// MongoDB bağlantısını başlat
 	InitMongoDB()
+	RunMigrations()
+	mailer := InitMailer()
+	srv := NewServer(NewMongoStores(database), mailer)
+	// JWT imzalama anahtarlarını yükle
+	InitKeys()
+	InitRateLimiter()
+	InitWebAuthn()
+	InitPasswordPolicy()
+	InitVerificationSecret()
+	srv.BootstrapAdmins()
+	srv.StartAccountPurger()
 
 	r := mux.NewRouter()
 	r.HandleFunc("/health", healthHandler).Methods("GET", "OPTIONS")
+	r.HandleFunc("/.well-known/jwks.json", jwksHandler).Methods("GET")
 
 	// Auth endpoints
-	r.HandleFunc("/send-code", sendCodeHandler).Methods("POST", "OPTIONS")
-	r.HandleFunc("/login", loginHandler).Methods("POST", "OPTIONS")
-	r.HandleFunc("/register", registerHandler).Methods("POST", "OPTIONS")
-	r.HandleFunc("/verify-token", verifyTokenHandler).Methods("POST", "OPTIONS")
-	r.HandleFunc("/forgot-password/send-code", sendPasswordResetCodeHandler).Methods("POST", "OPTIONS")
-	r.HandleFunc("/forgot-password/reset", resetPasswordHandler).Methods("POST", "OPTIONS")
+	r.Handle("/send-code", rateLimit("send-code", sendCodeLimits)(http.HandlerFunc(srv.sendCodeHandler))).Methods("POST", "OPTIONS")
+	r.Handle("/login", rateLimit("login", loginLimits)(http.HandlerFunc(srv.loginHandler))).Methods("POST", "OPTIONS")
+	r.Handle("/login/2fa", rateLimit("login-2fa", loginLimits)(http.HandlerFunc(srv.loginTwoFactorHandler))).Methods("POST", "OPTIONS")
+	r.Handle("/login/magic-link", rateLimit("magic-link", magicLinkLimits)(http.HandlerFunc(srv.requestMagicLinkHandler))).Methods("POST", "OPTIONS")
+	r.Handle("/login/magic-link/verify", rateLimit("magic-link-verify", socialTokenLimits)(http.HandlerFunc(srv.verifyMagicLinkHandler))).Methods("GET", "POST", "OPTIONS")
+	r.Handle("/login/email-code", rateLimit("login-code", sendCodeLimits)(http.HandlerFunc(srv.requestLoginCodeHandler))).Methods("POST", "OPTIONS")
+	r.Handle("/login/email-code/verify", rateLimit("login-code-verify", loginLimits)(http.HandlerFunc(srv.loginWithCodeHandler))).Methods("POST", "OPTIONS")
+	r.Handle("/login/unlock", rateLimit("login-unlock", socialTokenLimits)(http.HandlerFunc(srv.unlockAccountHandler))).Methods("GET", "POST")
+	r.HandleFunc("/register", srv.registerHandler).Methods("POST", "OPTIONS")
+	r.HandleFunc("/verify-token", srv.verifyTokenHandler).Methods("POST", "OPTIONS")
+	r.HandleFunc("/token/refresh", srv.refreshTokenHandler).Methods("POST", "OPTIONS")
+	r.HandleFunc("/logout", srv.logoutHandler).Methods("POST", "OPTIONS")
+	r.HandleFunc("/logout-all", srv.logoutAllHandler).Methods("POST", "OPTIONS")
+	r.Handle("/forgot-password/send-code", rateLimit("reset-code", resetCodeLimits)(http.HandlerFunc(srv.sendPasswordResetCodeHandler))).Methods("POST", "OPTIONS")
+	r.Handle("/forgot-password/reset", rateLimit("reset", resetPasswordLimits)(http.HandlerFunc(srv.resetPasswordHandler))).Methods("POST", "OPTIONS")
 
-	// Google OAuth endpoints
-	r.HandleFunc("/google/login", googleLoginHandler).Methods("GET", "OPTIONS")
-	r.HandleFunc("/google/callback", googleCallbackHandler).Methods("GET", "OPTIONS")
+	// Sosyal giriş endpoints: /<sağlayıcı>/login ve /<sağlayıcı>/callback
+	srv.handleOAuthProvider(r, googleLogin)
+	r.Handle("/google/token", rateLimit("google-token", socialTokenLimits)(srv.googleTokenHandler(NewRemoteKeySet(googleJWKSURL, nil)))).Methods("POST", "OPTIONS")
+	srv.handleOAuthProvider(r, facebookLogin)
+	// Yapılandırmadan gelen OIDC sağlayıcıları (Apple, Microsoft, üniversite SSO...)
+	for _, provider := range InitOIDCProviders() {
+		srv.handleOAuthProvider(r, provider)
+	}
 
 	// User profile endpoints
-	r.HandleFunc("/user/profile", getUserProfileHandler).Methods("GET", "OPTIONS")
-	r.HandleFunc("/user/profile", updateUserProfileHandler).Methods("PUT", "OPTIONS")
+	r.HandleFunc("/user/profile", srv.getUserProfileHandler).Methods("GET", "OPTIONS")
+	r.HandleFunc("/user/profile", srv.updateUserProfileHandler).Methods("PUT", "OPTIONS")
+	r.Handle("/user/email/change", rateLimit("email-change", sendCodeLimits)(http.HandlerFunc(srv.requestEmailChangeHandler))).Methods("POST", "OPTIONS")
+	r.Handle("/user/email/confirm", rateLimit("email-confirm", resetPasswordLimits)(http.HandlerFunc(srv.confirmEmailChangeHandler))).Methods("POST", "OPTIONS")
+	r.Handle("/user/email/undo", rateLimit("email-undo", socialTokenLimits)(http.HandlerFunc(srv.undoEmailChangeHandler))).Methods("GET", "POST")
+	r.Handle("/user/export", rateLimit("export", exportLimits)(http.HandlerFunc(srv.exportUserDataHandler))).Methods("GET", "OPTIONS")
+	r.Handle("/user/delete/code", rateLimit("delete-code", sendCodeLimits)(http.HandlerFunc(srv.sendDeleteAccountCodeHandler))).Methods("POST", "OPTIONS")
+	r.Handle("/user", rateLimit("delete-account", resetPasswordLimits)(http.HandlerFunc(srv.deleteAccountHandler))).Methods("DELETE", "OPTIONS")
+	r.HandleFunc("/user/security/logins", srv.loginHistoryHandler).Methods("GET", "OPTIONS")
+	r.Handle("/user/password", rateLimit("change-password", resetPasswordLimits)(http.HandlerFunc(srv.changePasswordHandler))).Methods("PUT", "OPTIONS")
+
+	// Hesap bağlama endpoints
+	r.HandleFunc("/user/identities", srv.listIdentitiesHandler).Methods("GET", "OPTIONS")
+	r.HandleFunc("/user/identities/{provider}", srv.linkIdentityHandler).Methods("POST", "OPTIONS")
+	r.HandleFunc("/user/identities/{provider}", srv.unlinkIdentityHandler).Methods("DELETE", "OPTIONS")
+
+	// Passkey (WebAuthn) endpoints
+	r.HandleFunc("/webauthn/register/begin", srv.beginWebAuthnRegistrationHandler).Methods("POST", "OPTIONS")
+	r.HandleFunc("/webauthn/register/finish", srv.finishWebAuthnRegistrationHandler).Methods("POST", "OPTIONS")
+	r.Handle("/webauthn/login/begin", rateLimit("webauthn-login", loginLimits)(http.HandlerFunc(beginWebAuthnLoginHandler))).Methods("POST", "OPTIONS")
+	r.Handle("/webauthn/login/finish", rateLimit("webauthn-login", loginLimits)(http.HandlerFunc(srv.finishWebAuthnLoginHandler))).Methods("POST", "OPTIONS")
+
+	// İki adımlı doğrulama endpoints
+	r.HandleFunc("/user/2fa/enroll", srv.enrollTwoFactorHandler).Methods("POST", "OPTIONS")
+	r.HandleFunc("/user/2fa/confirm", srv.confirmTwoFactorHandler).Methods("POST", "OPTIONS")
+	r.HandleFunc("/user/2fa/disable", srv.disableTwoFactorHandler).Methods("POST", "OPTIONS")
+
+	// Yönetici endpoints
+	r.Handle("/admin/users/{id}/lockout", srv.requirePermission(PermUsersWrite)(http.HandlerFunc(srv.adminClearLockoutHandler))).Methods("DELETE", "OPTIONS")
+	r.Handle("/admin/lockouts/ip/{ip}", srv.requirePermission(PermUsersWrite)(http.HandlerFunc(srv.adminClearLockoutHandler))).Methods("DELETE", "OPTIONS")
+	r.Handle("/admin/users", srv.requirePermission(PermUsersRead)(http.HandlerFunc(srv.adminListUsersHandler))).Methods("GET", "OPTIONS")
+	r.Handle("/admin/users/{id}", srv.requirePermission(PermUsersRead)(http.HandlerFunc(srv.adminGetUserHandler))).Methods("GET", "OPTIONS")
+	r.Handle("/admin/users/{id}/ban", srv.requirePermission(PermUsersWrite)(http.HandlerFunc(srv.adminBanUserHandler))).Methods("POST", "OPTIONS")
+	r.Handle("/admin/users/{id}/ban", srv.requirePermission(PermUsersWrite)(http.HandlerFunc(srv.adminUnbanUserHandler))).Methods("DELETE", "OPTIONS")
+	r.Handle("/admin/users/{id}/roles", srv.requirePermission(PermUsersWrite)(http.HandlerFunc(srv.adminSetRolesHandler))).Methods("PUT", "OPTIONS")
+	r.Handle("/admin/users/{id}/force-password-reset", srv.requirePermission(PermUsersWrite)(http.HandlerFunc(srv.adminForcePasswordResetHandler))).Methods("POST", "OPTIONS")
+	r.Handle("/admin/verifications/resend", srv.requirePermission(PermUsersWrite)(http.HandlerFunc(srv.adminResendVerificationHandler))).Methods("POST", "OPTIONS")
+	r.Handle("/admin/audit", srv.requirePermission(PermAuditRead)(http.HandlerFunc(srv.adminAuditLogHandler))).Methods("GET", "OPTIONS")
 
 	c := cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"},
@@ -39,7 +107,8 @@ func main() {
 		AllowCredentials: true,
 	})
 
-	handler := c.Handler(r)
+	// Geliştirici e-posta önizlemesi CORS dışında kalır (yalnızca MAIL_PREVIEW=1 iken)
+	handler := withMailPreview(c.Handler(r), mailer)
 
 	port := os.Getenv("PORT")
 	if port == "" {
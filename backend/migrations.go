@@ -0,0 +1,816 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	migrationLockID      = "lock"
+	migrationLockTTL     = 2 * time.Minute  // Süreç çökerse kilit bu süre sonunda devralınabilir
+	migrationLockRenew   = 30 * time.Second // Adımlar sürerken kilit bu aralıkla yenilenir
+	migrationLockRetry   = time.Second
+	loginEventRetention  = 180 * 24 * time.Hour
+	loginFailureTTL      = 48 * time.Hour  // En uzun hata penceresi + kilit süresinden uzun olmalı
+	rateLimitBucketTTL   = 24 * time.Hour  // Bu kadar boşta kalan kova zaten dolmuştur
+	migrationLockTimeout = 2 * time.Minute // Kilit başka bir süreçteyse en fazla bu kadar beklenir
+)
+
+// errMigrationLocked, adımları başka bir süreç uygularken kilit beklenen sürede alınamadığında döner.
+var errMigrationLocked = errors.New("migration kilidi başka bir süreçte")
+
+// migration, veritabanı şemasındaki tek bir sürümlü adımdır. Up birden fazla kez çalışsa da
+// aynı sonucu vermelidir (ör. aynı isimle index oluşturmak). Down, Up'ın yaptığını geri alır;
+// geri alınamayan veri değişiklikleri varsa Down'ın açıklamasında belirtilir.
+type migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database) error
+	Down    func(ctx context.Context, db *mongo.Database) error
+}
+
+// appliedMigration, schema_migrations koleksiyonundaki bir kayıttır.
+type appliedMigration struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"appliedAt"`
+}
+
+// migrations, sürüm sırasıyla tüm adımlardır. Yayınlanmış bir adım değiştirilmez; düzeltme
+// gerekiyorsa yeni bir sürüm eklenir.
+var migrations = []migration{
+	{
+		Version: 1,
+		Name:    "users_normalize_email",
+		// E-postalar normalizeEmail ile aynı biçime getirilir; büyük harfle kaydedilmiş hesaplar
+		// aksi halde küçük harfle yapılan aramalarda bulunamaz. Down eski yazımı geri getirmez.
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			users := db.Collection("users")
+			cursor, err := users.Find(ctx, bson.M{"email": bson.M{"$type": "string"}},
+				options.Find().SetProjection(bson.M{"email": 1}))
+			if err != nil {
+				return err
+			}
+			defer cursor.Close(ctx)
+			for cursor.Next(ctx) {
+				var doc struct {
+					ID    interface{} `bson:"_id"`
+					Email string      `bson:"email"`
+				}
+				if err := cursor.Decode(&doc); err != nil {
+					return err
+				}
+				if normalized := normalizeEmail(doc.Email); normalized != doc.Email {
+					if _, err := users.UpdateOne(ctx, bson.M{"_id": doc.ID}, bson.M{"$set": bson.M{"email": normalized}}); err != nil {
+						return err
+					}
+				}
+			}
+			return cursor.Err()
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return nil
+		},
+	},
+	{
+		Version: 2,
+		Name:    "users_merge_duplicates",
+		// İlk sürüm Google hesaplarını e-posta+sağlayıcı ile aradığı için aynı adrese ait bir şifreli
+		// ve bir Google kaydı oluşabiliyordu. Önce eski provider/socialId alanları identities'e
+		// taşınır, ardından aynı e-postaya sahip kayıtlar tek hesapta birleştirilir. Down
+		// birleştirilen hesapları geri ayırmaz.
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if err := backfillLegacyIdentities(ctx, db.Collection("users")); err != nil {
+				return err
+			}
+			return mergeDuplicateUsers(ctx, db)
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return nil
+		},
+	},
+	{
+		Version: 3,
+		Name:    "users_email_unique",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			users := db.Collection("users")
+			// users_merge_duplicates sonrasında kopya kalmamalıdır; kalırsa elle çözülmelidir
+			duplicates, err := findDuplicates(ctx, users, "email")
+			if err != nil {
+				return err
+			}
+			if len(duplicates) > 0 {
+				return fmt.Errorf("birden fazla hesapta kullanılan e-posta adresleri var, önce bunları birleştirin: %s", strings.Join(duplicates, ", "))
+			}
+			return createIndexes(ctx, users, mongo.IndexModel{
+				Keys:    bson.D{{Key: "email", Value: 1}},
+				Options: options.Index().SetName("users_email_unique").SetUnique(true),
+			})
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db.Collection("users"), "users_email_unique")
+		},
+	},
+	{
+		Version: 4,
+		Name:    "users_lookup_indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndexes(ctx, db.Collection("users"),
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "identities.provider", Value: 1}, {Key: "identities.subject", Value: 1}},
+					Options: options.Index().SetName("users_identity"),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "provider", Value: 1}, {Key: "socialId", Value: 1}},
+					Options: options.Index().SetName("users_legacy_social").SetSparse(true),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "purgeAt", Value: 1}},
+					Options: options.Index().SetName("users_purge_at").SetSparse(true),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "roles", Value: 1}},
+					Options: options.Index().SetName("users_roles"),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "createdAt", Value: -1}},
+					Options: options.Index().SetName("users_created_at"),
+				},
+			)
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db.Collection("users"),
+				"users_identity", "users_legacy_social", "users_purge_at", "users_roles", "users_created_at")
+		},
+	},
+	{
+		Version: 5,
+		Name:    "users_backfill_roles",
+		// Rolü olmayan hesaplar zaten RoleUser sayılıyor; alanı açıkça yazmak rol filtrelerini
+		// ve index'i basitleştirir. Down yalnızca tam olarak ["user"] olan değerleri kaldırır.
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("users").UpdateMany(ctx,
+				bson.M{"$or": bson.A{bson.M{"roles": bson.M{"$exists": false}}, bson.M{"roles": bson.M{"$size": 0}}}},
+				bson.M{"$set": bson.M{"roles": bson.A{RoleUser}}},
+			)
+			return err
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("users").UpdateMany(ctx,
+				bson.M{"roles": bson.A{RoleUser}},
+				bson.M{"$unset": bson.M{"roles": ""}},
+			)
+			return err
+		},
+	},
+	{
+		Version: 6,
+		Name:    "verification_codes_purpose",
+		// Amaç alanı olmayan eski kodlar yeni özet biçimiyle hiçbir zaman doğrulanamaz; bunlar
+		// silinir. Down index'leri kaldırır, silinen kodları geri getirmez.
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			codes := db.Collection("verification_codes")
+			if _, err := codes.DeleteMany(ctx, bson.M{"purpose": bson.M{"$exists": false}}); err != nil {
+				return err
+			}
+			return createIndexes(ctx, codes,
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "email", Value: 1}, {Key: "purpose", Value: 1}},
+					Options: options.Index().SetName("verification_codes_email_purpose").SetUnique(true),
+				},
+				ttlIndex("verification_codes_ttl", "expiresAt", 0),
+			)
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db.Collection("verification_codes"), "verification_codes_email_purpose", "verification_codes_ttl")
+		},
+	},
+	{
+		Version: 7,
+		Name:    "token_indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			// Eşzamanlı upsert'lerden kalmış olabilecek kopyalar zararsızdır, index'ten önce silinir
+			if err := removeDuplicates(ctx, db.Collection("revoked_tokens"), "jti"); err != nil {
+				return err
+			}
+			steps := []struct {
+				collection string
+				indexes    []mongo.IndexModel
+			}{
+				{"sessions", []mongo.IndexModel{
+					uniqueIndex("sessions_token_hash", "tokenHash"),
+					{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}, Options: options.Index().SetName("sessions_user")},
+					{Keys: bson.D{{Key: "familyId", Value: 1}}, Options: options.Index().SetName("sessions_family")},
+					ttlIndex("sessions_ttl", "expiresAt", 0),
+				}},
+				{"revoked_tokens", []mongo.IndexModel{
+					uniqueIndex("revoked_tokens_jti", "jti"),
+					ttlIndex("revoked_tokens_ttl", "expiresAt", 0),
+				}},
+				{"oauth_states", []mongo.IndexModel{
+					uniqueIndex("oauth_states_state_hash", "stateHash"),
+					ttlIndex("oauth_states_ttl", "expiresAt", 0),
+				}},
+				{"webauthn_sessions", []mongo.IndexModel{
+					uniqueIndex("webauthn_sessions_token_hash", "tokenHash"),
+					ttlIndex("webauthn_sessions_ttl", "expiresAt", 0),
+				}},
+				{"webauthn_credentials", []mongo.IndexModel{
+					uniqueIndex("webauthn_credentials_credential_id", "credentialId"),
+					{Keys: bson.D{{Key: "userId", Value: 1}}, Options: options.Index().SetName("webauthn_credentials_user")},
+				}},
+				{"magic_links", []mongo.IndexModel{
+					uniqueIndex("magic_links_token_hash", "tokenHash"),
+					ttlIndex("magic_links_ttl", "expiresAt", 0),
+				}},
+			}
+			for _, step := range steps {
+				if err := createIndexes(ctx, db.Collection(step.collection), step.indexes...); err != nil {
+					return fmt.Errorf("%s: %w", step.collection, err)
+				}
+			}
+			return nil
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			drops := map[string][]string{
+				"sessions":             {"sessions_token_hash", "sessions_user", "sessions_family", "sessions_ttl"},
+				"revoked_tokens":       {"revoked_tokens_jti", "revoked_tokens_ttl"},
+				"oauth_states":         {"oauth_states_state_hash", "oauth_states_ttl"},
+				"webauthn_sessions":    {"webauthn_sessions_token_hash", "webauthn_sessions_ttl"},
+				"webauthn_credentials": {"webauthn_credentials_credential_id", "webauthn_credentials_user"},
+				"magic_links":          {"magic_links_token_hash", "magic_links_ttl"},
+			}
+			for collection, names := range drops {
+				if err := dropIndexes(ctx, db.Collection(collection), names...); err != nil {
+					return fmt.Errorf("%s: %w", collection, err)
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 8,
+		Name:    "history_indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			steps := []struct {
+				collection string
+				indexes    []mongo.IndexModel
+			}{
+				{"login_events", []mongo.IndexModel{
+					{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}, Options: options.Index().SetName("login_events_user")},
+					{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "deviceId", Value: 1}}, Options: options.Index().SetName("login_events_device")},
+					ttlIndex("login_events_ttl", "createdAt", loginEventRetention),
+				}},
+				{"login_failures", []mongo.IndexModel{
+					{Keys: bson.D{{Key: "unlockTokenHash", Value: 1}}, Options: options.Index().SetName("login_failures_unlock").SetSparse(true)},
+					ttlIndex("login_failures_ttl", "lastFailureAt", loginFailureTTL),
+				}},
+				{"email_changes", []mongo.IndexModel{
+					{Keys: bson.D{{Key: "userId", Value: 1}}, Options: options.Index().SetName("email_changes_user")},
+					{Keys: bson.D{{Key: "undoTokenHash", Value: 1}}, Options: options.Index().SetName("email_changes_undo").SetSparse(true)},
+				}},
+				{"audit_log", []mongo.IndexModel{
+					{Keys: bson.D{{Key: "targetId", Value: 1}, {Key: "createdAt", Value: -1}}, Options: options.Index().SetName("audit_log_target")},
+					{Keys: bson.D{{Key: "actorId", Value: 1}, {Key: "createdAt", Value: -1}}, Options: options.Index().SetName("audit_log_actor")},
+					{Keys: bson.D{{Key: "action", Value: 1}, {Key: "createdAt", Value: -1}}, Options: options.Index().SetName("audit_log_action")},
+				}},
+				{"rate_limits", []mongo.IndexModel{
+					ttlIndex("rate_limits_ttl", "updatedAt", rateLimitBucketTTL),
+				}},
+			}
+			for _, step := range steps {
+				if err := createIndexes(ctx, db.Collection(step.collection), step.indexes...); err != nil {
+					return fmt.Errorf("%s: %w", step.collection, err)
+				}
+			}
+			return nil
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			drops := map[string][]string{
+				"login_events":   {"login_events_user", "login_events_device", "login_events_ttl"},
+				"login_failures": {"login_failures_unlock", "login_failures_ttl"},
+				"email_changes":  {"email_changes_user", "email_changes_undo"},
+				"audit_log":      {"audit_log_target", "audit_log_actor", "audit_log_action"},
+				"rate_limits":    {"rate_limits_ttl"},
+			}
+			for collection, names := range drops {
+				if err := dropIndexes(ctx, db.Collection(collection), names...); err != nil {
+					return fmt.Errorf("%s: %w", collection, err)
+				}
+			}
+			return nil
+		},
+	},
+}
+
+func uniqueIndex(name, field string) mongo.IndexModel {
+	return mongo.IndexModel{
+		Keys:    bson.D{{Key: field, Value: 1}},
+		Options: options.Index().SetName(name).SetUnique(true),
+	}
+}
+
+// ttlIndex, field + after zamanı geçen belgeleri MongoDB'nin kendisinin silmesini sağlar.
+func ttlIndex(name, field string, after time.Duration) mongo.IndexModel {
+	return mongo.IndexModel{
+		Keys:    bson.D{{Key: field, Value: 1}},
+		Options: options.Index().SetName(name).SetExpireAfterSeconds(int32(after.Seconds())),
+	}
+}
+
+// createIndexes, index'leri oluşturur. Aynı isim ve tanımla var olan index'ler için işlem yapılmaz.
+func createIndexes(ctx context.Context, coll *mongo.Collection, models ...mongo.IndexModel) error {
+	_, err := coll.Indexes().CreateMany(ctx, models)
+	return err
+}
+
+// dropIndexes, index'leri siler; zaten olmayan index'ler hata sayılmaz.
+func dropIndexes(ctx context.Context, coll *mongo.Collection, names ...string) error {
+	for _, name := range names {
+		_, err := coll.Indexes().DropOne(ctx, name)
+		var cmdErr mongo.CommandError
+		if errors.As(err, &cmdErr) && (cmdErr.Code == 27 || cmdErr.Code == 26) { // IndexNotFound, NamespaceNotFound
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// findDuplicates, field değeri birden fazla belgede bulunan değerleri döndürür.
+func findDuplicates(ctx context.Context, coll *mongo.Collection, field string) ([]string, error) {
+	cursor, err := coll.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$" + field, "count": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		{{Key: "$limit", Value: 20}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var groups []struct {
+		Value interface{} `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+	values := make([]string, 0, len(groups))
+	for _, g := range groups {
+		values = append(values, fmt.Sprint(g.Value))
+	}
+	return values, nil
+}
+
+// removeDuplicates, field değeri aynı olan belgelerden ilki dışındakileri siler.
+func removeDuplicates(ctx context.Context, coll *mongo.Collection, field string) error {
+	cursor, err := coll.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+		{{Key: "$group", Value: bson.M{"_id": "$" + field, "ids": bson.M{"$push": "$_id"}, "count": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var group struct {
+			IDs bson.A `bson:"ids"`
+		}
+		if err := cursor.Decode(&group); err != nil {
+			return err
+		}
+		if _, err := coll.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": group.IDs[1:]}}); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// backfillLegacyIdentities, identities alanı eklenmeden önce oluşturulmuş sosyal hesapların
+// provider/socialId değerini kimlik olarak ekler. İlk sürüm Google kayıtlarında socialId
+// sağlayıcı kimliği değil e-posta olduğu için bunlar atlanır; ilk Google girişinde
+// resolveSocialUser tarafından sahiplenilirler.
+func backfillLegacyIdentities(ctx context.Context, users *mongo.Collection) error {
+	cursor, err := users.Find(ctx, bson.M{
+		"provider":   bson.M{"$nin": bson.A{"", "email"}},
+		"socialId":   bson.M{"$nin": bson.A{nil, ""}},
+		"identities": bson.M{"$in": bson.A{nil, bson.A{}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var user User
+		if err := cursor.Decode(&user); err != nil {
+			return err
+		}
+		if user.Provider == "google" && normalizeEmail(user.SocialID) == user.Email {
+			continue
+		}
+		linkedAt := user.CreatedAt
+		if linkedAt.IsZero() {
+			linkedAt = user.ID.Timestamp()
+		}
+		identity := Identity{Provider: user.Provider, Subject: user.SocialID, Email: user.Email, LinkedAt: linkedAt}
+		if _, err := users.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"identities": bson.A{identity}}}); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// mergeDuplicateUsers, aynı e-postaya sahip kayıtları tek hesapta birleştirir. Silinmemiş,
+// şifreli ve en eski kayıt tutulur; diğerlerinin kimlikleri, rolleri, izinleri ve tutulan
+// hesapta olmayan şifre veya 2FA ayarı ona taşınır. Giriş geçmişi ve e-posta değişiklikleri
+// tutulan hesaba aktarılır; oturumlar, passkey'ler (kullanıcı kimliğine bağlı oldukları için)
+// ve geçici kayıtlar silinir.
+func mergeDuplicateUsers(ctx context.Context, db *mongo.Database) error {
+	users := db.Collection("users")
+	cursor, err := users.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$email", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	})
+	if err != nil {
+		return err
+	}
+	var groups []struct {
+		Email string `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return err
+	}
+
+	for _, group := range groups {
+		var accounts []User
+		cursor, err := users.Find(ctx, bson.M{"email": group.Email}, options.Find().SetSort(bson.M{"_id": 1}))
+		if err != nil {
+			return err
+		}
+		if err := cursor.All(ctx, &accounts); err != nil {
+			return err
+		}
+		if len(accounts) < 2 {
+			continue
+		}
+		sort.SliceStable(accounts, func(i, j int) bool {
+			if (accounts[i].DeletedAt == nil) != (accounts[j].DeletedAt == nil) {
+				return accounts[i].DeletedAt == nil
+			}
+			return accounts[i].Sifre != "" && accounts[j].Sifre == ""
+		})
+		keeper, duplicates := accounts[0], accounts[1:]
+		if err := mergeUsers(ctx, db, &keeper, duplicates); err != nil {
+			return fmt.Errorf("%s: %w", group.Email, err)
+		}
+		log.Printf("Aynı e-postaya sahip %d hesap birleştirildi: %s", len(accounts), keeper.ID.Hex())
+	}
+	return nil
+}
+
+// mergeUsers, duplicates kayıtlarını keeper'a taşır ve siler.
+func mergeUsers(ctx context.Context, db *mongo.Database, keeper *User, duplicates []User) error {
+	set := bson.M{}
+	ids := make(bson.A, 0, len(duplicates))
+	for _, dup := range duplicates {
+		ids = append(ids, dup.ID)
+		for _, identity := range dup.Identities {
+			if hasIdentityProvider(keeper.Identities, identity.Provider) {
+				// Hesap başına sağlayıcı başına tek kimlik bağlanabilir
+				log.Printf("Birleştirmede %s kimliği aktarılmadı (%s): hesapta zaten bu sağlayıcı bağlı", identity.Provider, dup.ID.Hex())
+				continue
+			}
+			keeper.Identities = append(keeper.Identities, identity)
+			set["identities"] = keeper.Identities
+		}
+		if roles := normalizeList(append(userRoles(keeper), dup.Roles...)); len(roles) != len(userRoles(keeper)) {
+			keeper.Roles = roles
+			set["roles"] = roles
+		}
+		if permissions := normalizeList(append(keeper.Permissions, dup.Permissions...)); len(permissions) != len(keeper.Permissions) {
+			keeper.Permissions = permissions
+			set["permissions"] = permissions
+		}
+		if keeper.Sifre == "" && dup.Sifre != "" {
+			keeper.Sifre = dup.Sifre
+			set["sifre"] = dup.Sifre
+		}
+		if !keeper.TOTPEnabled && dup.TOTPEnabled {
+			keeper.TOTPEnabled, keeper.TOTPSecret, keeper.TOTPLastStep, keeper.RecoveryCodes = true, dup.TOTPSecret, dup.TOTPLastStep, dup.RecoveryCodes
+			set["totpEnabled"], set["totpSecret"], set["totpLastStep"], set["recoveryCodes"] = true, dup.TOTPSecret, dup.TOTPLastStep, dup.RecoveryCodes
+		}
+	}
+
+	if len(set) > 0 {
+		if _, err := db.Collection("users").UpdateOne(ctx, bson.M{"_id": keeper.ID}, bson.M{"$set": set}); err != nil {
+			return err
+		}
+	}
+	for _, name := range []string{"login_events", "email_changes"} {
+		if _, err := db.Collection(name).UpdateMany(ctx, bson.M{"userId": bson.M{"$in": ids}}, bson.M{"$set": bson.M{"userId": keeper.ID}}); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	for _, name := range []string{"sessions", "webauthn_credentials", "webauthn_sessions", "magic_links"} {
+		if _, err := db.Collection(name).DeleteMany(ctx, bson.M{"userId": bson.M{"$in": ids}}); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	if _, err := db.Collection("oauth_states").DeleteMany(ctx, bson.M{"linkUserId": bson.M{"$in": ids}}); err != nil {
+		return fmt.Errorf("oauth_states: %w", err)
+	}
+	for _, dup := range duplicates {
+		if _, err := db.Collection("login_failures").DeleteOne(ctx, bson.M{"_id": accountLockoutKey(dup.ID)}); err != nil {
+			return fmt.Errorf("login_failures: %w", err)
+		}
+	}
+	_, err := db.Collection("users").DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	return err
+}
+
+func hasIdentityProvider(identities []Identity, provider string) bool {
+	for _, identity := range identities {
+		if identity.Provider == provider {
+			return true
+		}
+	}
+	return false
+}
+
+// Migrator, adımları uygular ve schema_migrations koleksiyonuna kaydeder. Birden fazla
+// sunucu aynı anda başlasa da adımlar yalnızca bir kez, sırayla çalışır.
+type Migrator struct {
+	db         *mongo.Database
+	records    *mongo.Collection
+	migrations []migration
+	owner      string
+}
+
+func NewMigrator(db *mongo.Database) *Migrator {
+	host, _ := os.Hostname()
+	sorted := append([]migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	return &Migrator{
+		db:         db,
+		records:    db.Collection("schema_migrations"),
+		migrations: sorted,
+		owner:      fmt.Sprintf("%s:%d:%d", host, os.Getpid(), time.Now().UnixNano()),
+	}
+}
+
+// lock, schema_migrations içindeki kilit belgesini alır ve adımlar sürdükçe yeniler; uzun
+// bir backfill sırasında kilidin süresi dolup başka bir sürece geçmesi böylece engellenir.
+// Kilit başka bir süreçteyse en fazla migrationLockTimeout kadar bekler; adımların süresi bu
+// beklemeyle sınırlı değildir. Dönen context, kilit kaybedilirse iptal edilir; release
+// yenilemeyi durdurur ve kilidi bırakır.
+func (m *Migrator) lock(ctx context.Context) (context.Context, func(), error) {
+	acquireCtx, cancelAcquire := context.WithTimeout(ctx, migrationLockTimeout)
+	err := m.acquire(acquireCtx)
+	cancelAcquire()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lockCtx, cancel := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(migrationLockRenew)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-lockCtx.Done():
+				return
+			case <-ticker.C:
+				if err := m.renew(lockCtx); err != nil {
+					if lockCtx.Err() == nil {
+						log.Printf("Migration kilidi yenilenemedi, adımlar durduruluyor: %v", err)
+					}
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	release := func() {
+		cancel()
+		<-stopped
+		m.unlock(context.Background())
+	}
+	return lockCtx, release, nil
+}
+
+// renew, kilidin süresini uzatır. Kilit artık bu sürece ait değilse hata döner.
+func (m *Migrator) renew(ctx context.Context) error {
+	result, err := m.records.UpdateOne(ctx,
+		bson.M{"_id": migrationLockID, "owner": m.owner},
+		bson.M{"$set": bson.M{"expiresAt": time.Now().Add(migrationLockTTL)}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("migration kilidi başka bir sürece geçti")
+	}
+	return nil
+}
+
+// acquire, kilit belgesini boşsa veya süresi dolmuşsa alır; değilse bekler.
+func (m *Migrator) acquire(ctx context.Context) error {
+	for {
+		now := time.Now()
+		_, err := m.records.UpdateOne(ctx,
+			bson.M{"_id": migrationLockID, "expiresAt": bson.M{"$lt": now}},
+			bson.M{"$set": bson.M{"owner": m.owner, "expiresAt": now.Add(migrationLockTTL)}},
+			options.Update().SetUpsert(true),
+		)
+		if err == nil {
+			return nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %v", errMigrationLocked, ctx.Err())
+		case <-time.After(migrationLockRetry):
+		}
+	}
+}
+
+func (m *Migrator) unlock(ctx context.Context) {
+	if _, err := m.records.DeleteOne(ctx, bson.M{"_id": migrationLockID, "owner": m.owner}); err != nil {
+		log.Printf("Migration kilidi bırakılamadı: %v", err)
+	}
+}
+
+// applied, uygulanmış adımları sürüm sırasıyla döndürür.
+func (m *Migrator) applied(ctx context.Context) ([]appliedMigration, error) {
+	cursor, err := m.records.Find(ctx,
+		bson.M{"_id": bson.M{"$ne": migrationLockID}},
+		options.Find().SetSort(bson.M{"_id": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var records []appliedMigration
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Up, uygulanmamış tüm adımları sırayla çalıştırır. Bir adım hata verirse sonrakiler
+// çalıştırılmaz; hata veren adım kaydedilmediği için bir sonraki çalıştırmada tekrar denenir.
+func (m *Migrator) Up(ctx context.Context) error {
+	ctx, release, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	records, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	done := map[int]bool{}
+	for _, rec := range records {
+		done[rec.Version] = true
+	}
+
+	for _, mig := range m.migrations {
+		if done[mig.Version] {
+			continue
+		}
+		start := time.Now()
+		if err := mig.Up(ctx, m.db); err != nil {
+			return fmt.Errorf("migration %d (%s) başarısız: %w", mig.Version, mig.Name, err)
+		}
+		_, err := m.records.InsertOne(ctx, appliedMigration{Version: mig.Version, Name: mig.Name, AppliedAt: time.Now()})
+		if err != nil {
+			return err
+		}
+		log.Printf("Migration uygulandı: %d %s (%s)", mig.Version, mig.Name, time.Since(start).Round(time.Millisecond))
+	}
+	return nil
+}
+
+// Down, son uygulanan steps adımı tersten geri alır.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	ctx, release, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	records, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	byVersion := map[int]migration{}
+	for _, mig := range m.migrations {
+		byVersion[mig.Version] = mig
+	}
+
+	for i := len(records) - 1; i >= 0 && steps > 0; i, steps = i-1, steps-1 {
+		mig, ok := byVersion[records[i].Version]
+		if !ok {
+			return fmt.Errorf("migration %d kodda bulunamadı, geri alınamaz", records[i].Version)
+		}
+		if err := mig.Down(ctx, m.db); err != nil {
+			return fmt.Errorf("migration %d (%s) geri alınamadı: %w", mig.Version, mig.Name, err)
+		}
+		if _, err := m.records.DeleteOne(ctx, bson.M{"_id": mig.Version}); err != nil {
+			return err
+		}
+		log.Printf("Migration geri alındı: %d %s", mig.Version, mig.Name)
+	}
+	return nil
+}
+
+// Status, her adımın uygulanıp uygulanmadığını yazdırır.
+func (m *Migrator) Status(ctx context.Context) error {
+	records, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	appliedAt := map[int]time.Time{}
+	for _, rec := range records {
+		appliedAt[rec.Version] = rec.AppliedAt
+	}
+	for _, mig := range m.migrations {
+		state := "bekliyor"
+		if at, ok := appliedAt[mig.Version]; ok {
+			state = "uygulandı " + at.Format(time.RFC3339)
+		}
+		fmt.Printf("%4d  %-30s %s\n", mig.Version, mig.Name, state)
+	}
+	return nil
+}
+
+// RunMigrations, sunucu açılırken bekleyen adımları uygular. MIGRATE_ON_STARTUP=false ile
+// kapatılabilir; bu durumda adımlar "migrate" komutuyla elle çalıştırılmalıdır. Adımlar
+// süre sınırı olmadan çalışır. Aynı anda açılan başka bir sunucu adımları uygularken kilit
+// alınamazsa açılış durdurulmaz; adımları kilidi tutan süreç tamamlar.
+func RunMigrations() {
+	if os.Getenv("MIGRATE_ON_STARTUP") == "false" {
+		return
+	}
+	err := NewMigrator(database).Up(context.Background())
+	if errors.Is(err, errMigrationLocked) {
+		log.Printf("Migration'lar başka bir sunucu tarafından uygulanıyor, açılışa devam ediliyor: %v", err)
+		return
+	}
+	if err != nil {
+		log.Fatal("Migration hatası: ", err)
+	}
+}
+
+// runMigrateCommand, "migrate [up|down [adım]|status]" komutunu çalıştırır.
+func runMigrateCommand(args []string) {
+	InitMongoDB()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+	migrator := NewMigrator(database)
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	var err error
+	switch command {
+	case "up":
+		err = migrator.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				log.Fatalf("Geçersiz adım sayısı: %q", args[1])
+			}
+		}
+		err = migrator.Down(ctx, steps)
+	case "status":
+		err = migrator.Status(ctx)
+	default:
+		log.Fatalf("Bilinmeyen migrate komutu: %q (up, down [adım], status)", command)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// migrationTestDB, MONGO_TEST_URI tanımlıysa test için geçici bir veritabanı açar.
+func migrationTestDB(t *testing.T) *mongo.Database {
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI tanımlı değil")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("MongoDB bağlantısı kurulamadı: %v", err)
+	}
+	db := client.Database("etkinlik_test_" + primitive.NewObjectID().Hex())
+	t.Cleanup(func() {
+		db.Drop(context.Background())
+		client.Disconnect(context.Background())
+	})
+	return db
+}
+
+func TestMigrationsMergeLegacyDuplicates(t *testing.T) {
+	db := migrationTestDB(t)
+	ctx := context.Background()
+	users := db.Collection("users")
+
+	// İlk sürümde aynı adrese ait bir şifreli hesap ve socialId'si e-posta olan bir Google
+	// kaydı oluşabiliyordu; Facebook kaydı ise sağlayıcı kimliğini taşıyor
+	password := User{ID: primitive.NewObjectID(), Email: "Ayse@Example.com", Sifre: "ozet", Provider: "email", CreatedAt: time.Now()}
+	google := User{ID: primitive.NewObjectID(), Email: "ayse@example.com", Provider: "google", SocialID: "ayse@example.com", Roles: []string{RoleOrganizer}, CreatedAt: time.Now()}
+	facebook := User{ID: primitive.NewObjectID(), Email: "mehmet@example.com", Provider: "facebook", SocialID: "fb-42", CreatedAt: time.Now()}
+	for _, user := range []User{password, google, facebook} {
+		if _, err := users.InsertOne(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Collection("login_events").InsertOne(ctx, bson.M{"userId": google.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewMigrator(db).Up(ctx); err != nil {
+		t.Fatalf("migration'lar uygulanamadı: %v", err)
+	}
+
+	if n, _ := users.CountDocuments(ctx, bson.M{"email": "ayse@example.com"}); n != 1 {
+		t.Fatalf("ayse@example.com için %d hesap kaldı", n)
+	}
+	var merged User
+	if err := users.FindOne(ctx, bson.M{"email": "ayse@example.com"}).Decode(&merged); err != nil {
+		t.Fatal(err)
+	}
+	if merged.ID != password.ID || merged.Sifre != "ozet" {
+		t.Errorf("şifreli hesap tutulmadı: %+v", merged)
+	}
+	if roles := normalizeList(merged.Roles); len(roles) != 2 {
+		t.Errorf("roller birleştirilmedi: %v", merged.Roles)
+	}
+	if n, _ := db.Collection("login_events").CountDocuments(ctx, bson.M{"userId": password.ID}); n != 1 {
+		t.Error("giriş geçmişi tutulan hesaba aktarılmadı")
+	}
+
+	var fb User
+	if err := users.FindOne(ctx, bson.M{"_id": facebook.ID}).Decode(&fb); err != nil {
+		t.Fatal(err)
+	}
+	if len(fb.Identities) != 1 || fb.Identities[0].Provider != "facebook" || fb.Identities[0].Subject != "fb-42" {
+		t.Errorf("eski sosyal kimlik taşınmadı: %+v", fb.Identities)
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/oauth2"
+)
+
+const (
+	oauthStateCookie = "oauth_state"
+	oauthStateTTL    = 10 * time.Minute
+
+	// Uygulamanın varsayılan derin bağlantısı
+	defaultLoginRedirect = "etkinlikuygulamasi://login/success"
+)
+
+var (
+	errOAuthStateInvalid  = errors.New("OAuth state geçersiz veya süresi dolmuş")
+	errRedirectNotAllowed = errors.New("yönlendirme adresine izin verilmiyor")
+)
+
+// loginRedirectAllowlist, OAUTH_REDIRECT_ALLOWLIST (virgülle ayrılmış) değişkeninden okunur.
+// Girdiler tam adres olarak karşılaştırılır; "*" ile biten girdiler önek olarak eşleşir.
+func loginRedirectAllowlist() []string {
+	allowlist := []string{defaultLoginRedirect}
+	for _, entry := range strings.Split(os.Getenv("OAUTH_REDIRECT_ALLOWLIST"), ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			allowlist = append(allowlist, entry)
+		}
+	}
+	return allowlist
+}
+
+// resolveLoginRedirect, istemcinin istediği giriş sonrası adresini allowlist'e göre doğrular.
+// Boş istekte varsayılan derin bağlantı döner.
+func resolveLoginRedirect(requested string) (string, error) {
+	if requested == "" {
+		return defaultLoginRedirect, nil
+	}
+
+	parsed, err := url.Parse(requested)
+	if err != nil || parsed.Scheme == "" || parsed.Fragment != "" || parsed.User != nil {
+		return "", errRedirectNotAllowed
+	}
+	withoutQuery := *parsed
+	withoutQuery.RawQuery = ""
+	target := withoutQuery.String()
+
+	for _, allowed := range loginRedirectAllowlist() {
+		if prefix, ok := strings.CutSuffix(allowed, "*"); ok {
+			if strings.HasPrefix(target, prefix) {
+				return requested, nil
+			}
+		} else if target == allowed {
+			return requested, nil
+		}
+	}
+	return "", errRedirectNotAllowed
+}
+
+// buildLoginRedirect, giriş sonrası adresine token parametrelerini ekler.
+func buildLoginRedirect(base string, params map[string]string) (string, error) {
+	parsed, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	query := parsed.Query()
+	for k, v := range params {
+		query.Set(k, v)
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
+
+// beginOAuthState, her giriş isteği için rastgele bir state ve PKCE doğrulayıcısı üretir.
+// State sunucuda saklanır ve aynı değer tarayıcıya çerez olarak yazılır; geri dönüşte
+// ikisi birlikte kontrol edilerek isteğin bu tarayıcıdan başladığı doğrulanır.
+func (s *Server) beginOAuthState(ctx context.Context, w http.ResponseWriter, flow OAuthState) (string, error) {
+	state, err := generateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	flow.StateHash = hashToken(state)
+	flow.CreatedAt = now
+	flow.ExpiresAt = now.Add(oauthStateTTL)
+	if err = s.oauthStates.Create(ctx, &flow); err != nil {
+		return "", err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/",
+		MaxAge:   int(oauthStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode, // form_post ile dönen sağlayıcılar için (ör. Apple)
+	})
+	return state, nil
+}
+
+// consumeOAuthState, geri dönüşteki state'i çerezle karşılaştırır ve sunucudaki kaydı
+// tek seferlik olarak siler.
+func (s *Server) consumeOAuthState(ctx context.Context, w http.ResponseWriter, r *http.Request, provider string) (*OAuthState, error) {
+	// Çerez her durumda temizlenir
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+	})
+
+	state := r.FormValue("state")
+	cookie, err := r.Cookie(oauthStateCookie)
+	if state == "" || err != nil || subtle.ConstantTimeCompare([]byte(state), []byte(cookie.Value)) != 1 {
+		return nil, errOAuthStateInvalid
+	}
+
+	stored, err := s.oauthStates.Consume(ctx, hashToken(state), provider, time.Now())
+	if err == errNotFound {
+		return nil, errOAuthStateInvalid
+	}
+	return stored, err
+}
+
+// SocialProfile, sağlayıcıdan bağımsız olarak sosyal girişten dönen kullanıcı bilgisidir.
+type SocialProfile struct {
+	Subject       string // Sağlayıcıdaki kalıcı kullanıcı kimliği
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+}
+
+// OAuthProvider, yetkilendirme kodu akışıyla giriş yapılan bir sosyal sağlayıcıdır.
+// Uç noktalar yapılandırmadan geldiği için akışlar yerel sahte bir OAuth sunucusuna
+// karşı da çalıştırılabilir.
+type OAuthProvider interface {
+	Name() string
+	OAuthConfig() *oauth2.Config
+	// nonce, OIDC sağlayıcılarında ID token'ı bu isteğe bağlamak için kullanılır
+	AuthCodeOptions(nonce string) []oauth2.AuthCodeOption
+	FetchProfile(ctx context.Context, token *oauth2.Token, nonce string) (*SocialProfile, error)
+}
+
+// oauthProviders, ada göre kayıtlı sosyal giriş sağlayıcılarıdır.
+var oauthProviders = map[string]OAuthProvider{}
+
+// handleOAuthProvider, sağlayıcıyı kaydeder ve giriş/geri dönüş adreslerini router'a ekler.
+// POST, yanıtı form_post ile gönderen sağlayıcılar içindir.
+func (s *Server) handleOAuthProvider(r *mux.Router, provider OAuthProvider) {
+	oauthProviders[provider.Name()] = provider
+	r.HandleFunc("/"+provider.Name()+"/login", s.oauthLoginHandler(provider)).Methods("GET", "OPTIONS")
+	r.HandleFunc("/"+provider.Name()+"/callback", s.oauthCallbackHandler(provider)).Methods("GET", "POST", "OPTIONS")
+}
+
+// oauthLoginHandler, sağlayıcının yetkilendirme sayfasına yönlendirir.
+func (s *Server) oauthLoginHandler(provider OAuthProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		redirectURI, err := resolveLoginRedirect(r.URL.Query().Get("redirect_uri"))
+		if err != nil {
+			http.Error(w, "Yönlendirme adresine izin verilmiyor", http.StatusBadRequest)
+			return
+		}
+
+		// Hesap bağlama akışı, oturum açmış kullanıcının aldığı kısa ömürlü biletle başlar
+		var linkUserID primitive.ObjectID
+		if ticket := r.URL.Query().Get("link_ticket"); ticket != "" {
+			claims, err := s.parsePurposeToken(ticket, "link:"+provider.Name())
+			if err != nil {
+				http.Error(w, "Bağlama bileti geçersiz veya süresi dolmuş", http.StatusUnauthorized)
+				return
+			}
+			if linkUserID, err = primitive.ObjectIDFromHex(claims.Subject); err != nil {
+				http.Error(w, "Bağlama bileti geçersiz", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		nonce, err := generateOpaqueToken()
+		if err != nil {
+			http.Error(w, "Sunucu hatası", http.StatusInternalServerError)
+			return
+		}
+
+		verifier := oauth2.GenerateVerifier()
+		state, err := s.beginOAuthState(context.Background(), w, OAuthState{
+			Provider:     provider.Name(),
+			CodeVerifier: verifier,
+			Nonce:        nonce,
+			LinkUserID:   linkUserID,
+			RedirectURI:  redirectURI,
+		})
+		if err != nil {
+			log.Printf("OAuth state kaydetme hatası: %v", err)
+			http.Error(w, "Sunucu hatası", http.StatusInternalServerError)
+			return
+		}
+
+		opts := append(provider.AuthCodeOptions(nonce), oauth2.S256ChallengeOption(verifier))
+		url := provider.OAuthConfig().AuthCodeURL(state, opts...)
+		http.Redirect(w, r, url, http.StatusTemporaryRedirect)
+	}
+}
+
+// oauthCallbackHandler, sağlayıcıdan dönen kodu token'a çevirir, kullanıcıyı bulur veya
+// oluşturur ve token ikilisiyle uygulamaya geri yönlendirir.
+func (s *Server) oauthCallbackHandler(provider OAuthProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.Background()
+
+		oauthState, err := s.consumeOAuthState(ctx, w, r, provider.Name())
+		if err == errOAuthStateInvalid {
+			s.recordLoginEvent(r, nil, "", provider.Name(), false, "invalid_state")
+			http.Error(w, "State geçersiz", http.StatusBadRequest)
+			return
+		} else if err != nil {
+			log.Printf("OAuth state okuma hatası: %v", err)
+			http.Error(w, "Sunucu hatası", http.StatusInternalServerError)
+			return
+		}
+
+		code := r.FormValue("code")
+		token, err := provider.OAuthConfig().Exchange(ctx, code, oauth2.VerifierOption(oauthState.CodeVerifier))
+		if err != nil {
+			s.recordLoginEvent(r, nil, "", provider.Name(), false, "code_exchange_failed")
+			http.Error(w, "Token alınamadı", http.StatusInternalServerError)
+			return
+		}
+
+		profile, err := provider.FetchProfile(ctx, token, oauthState.Nonce)
+		if err != nil {
+			log.Printf("%s kullanıcı bilgisi hatası: %v", provider.Name(), err)
+			s.recordLoginEvent(r, nil, "", provider.Name(), false, "invalid_profile")
+			http.Error(w, "Kullanıcı bilgileri alınamadı", http.StatusInternalServerError)
+			return
+		}
+		if profile.Email == "" {
+			http.Error(w, "Sağlayıcı e-posta adresi paylaşmadı", http.StatusBadRequest)
+			return
+		}
+
+		if !oauthState.LinkUserID.IsZero() {
+			s.finishIdentityLink(w, r, oauthState, provider.Name(), profile)
+			return
+		}
+
+		user, err := s.resolveSocialUser(ctx, provider.Name(), profile)
+		if err == errAccountExists {
+			s.recordLoginEvent(r, nil, profile.Email, provider.Name(), false, "account_exists")
+			redirectWithError(w, r, oauthState.RedirectURI, "account_exists")
+			return
+		} else if err != nil {
+			log.Printf("Sosyal kullanıcı kaydetme hatası: %v", err)
+			http.Error(w, "Kayıt başarısız", http.StatusInternalServerError)
+			return
+		}
+		if err := checkUserActive(user); err != nil {
+			s.recordLoginEvent(r, user, user.Email, provider.Name(), false, inactiveReason(err))
+			redirectWithError(w, r, oauthState.RedirectURI, "account_disabled")
+			return
+		}
+
+		pair, err := s.issueLoginTokens(ctx, user, r, provider.Name())
+		if err != nil {
+			http.Error(w, "Token oluşturma başarısız", http.StatusInternalServerError)
+			return
+		}
+
+		// Derin bağlantı ile uygulamaya dön (görünür HTML olmadan)
+		redirectURL, err := buildLoginRedirect(oauthState.RedirectURI, map[string]string{
+			"token":        pair.AccessToken,
+			"refreshToken": pair.RefreshToken,
+			"type":         provider.Name(),
+		})
+		if err != nil {
+			http.Error(w, "Yönlendirme adresi oluşturulamadı", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		http.Redirect(w, r, redirectURL, http.StatusFound)
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// fakeOAuthServer, sağlayıcının authorize, token ve kullanıcı bilgisi uç noktalarını taklit
+// eder. Kod PKCE challenge'ına bağlanır; token yalnızca doğru doğrulayıcıyla verilir.
+type fakeOAuthServer struct {
+	*httptest.Server
+	t            *testing.T
+	clientSecret string
+	requireProof bool // Facebook gibi appsecret_proof isteyen sağlayıcılar için
+
+	mu      sync.Mutex
+	profile map[string]interface{} // Sıradaki girişte dönecek kullanıcı bilgisi
+	codes   map[string]string      // kod -> code_challenge
+	tokens  map[string]bool
+}
+
+func newFakeOAuthServer(t *testing.T, clientSecret string) *fakeOAuthServer {
+	f := &fakeOAuthServer{t: t, clientSecret: clientSecret, codes: map[string]string{}, tokens: map[string]bool{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authorize", f.authorize)
+	mux.HandleFunc("/token", f.token)
+	mux.HandleFunc("/userinfo", f.userInfo)
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeOAuthServer) setProfile(profile map[string]interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.profile = profile
+}
+
+// authorize, kullanıcı onay vermiş gibi kodu ve state'i redirect_uri'ye geri gönderir.
+func (f *fakeOAuthServer) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		http.Error(w, "PKCE gerekli", http.StatusBadRequest)
+		return
+	}
+	code, err := generateOpaqueToken()
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	f.mu.Lock()
+	f.codes[code] = query.Get("code_challenge")
+	f.mu.Unlock()
+
+	callback, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "Geçersiz redirect_uri", http.StatusBadRequest)
+		return
+	}
+	values := callback.Query()
+	values.Set("code", code)
+	values.Set("state", query.Get("state"))
+	callback.RawQuery = values.Encode()
+	http.Redirect(w, r, callback.String(), http.StatusFound)
+}
+
+func (f *fakeOAuthServer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Geçersiz form", http.StatusBadRequest)
+		return
+	}
+	secret := r.PostForm.Get("client_secret")
+	if _, password, ok := r.BasicAuth(); ok {
+		secret, _ = url.QueryUnescape(password)
+	}
+	if secret != f.clientSecret {
+		http.Error(w, `{"error": "invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+
+	f.mu.Lock()
+	challenge, ok := f.codes[r.PostForm.Get("code")]
+	delete(f.codes, r.PostForm.Get("code")) // Kod tek kullanımlık
+	f.mu.Unlock()
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "invalid_grant"}`))
+		return
+	}
+
+	accessToken, err := generateOpaqueToken()
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	f.mu.Lock()
+	f.tokens[accessToken] = true
+	f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+	})
+}
+
+func (f *fakeOAuthServer) userInfo(w http.ResponseWriter, r *http.Request) {
+	accessToken := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	f.mu.Lock()
+	valid := f.tokens[accessToken]
+	profile := f.profile
+	f.mu.Unlock()
+	if !valid {
+		http.Error(w, "Geçersiz token", http.StatusUnauthorized)
+		return
+	}
+	// appsecret_proof, access token'ın uygulama sırrıyla HMAC'idir
+	if f.requireProof {
+		mac := hmac.New(sha256.New, []byte(f.clientSecret))
+		mac.Write([]byte(accessToken))
+		if r.URL.Query().Get("appsecret_proof") != hex.EncodeToString(mac.Sum(nil)) {
+			http.Error(w, "Geçersiz appsecret_proof", http.StatusBadRequest)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+// oauthTestConfig, sahte sunucuya yönlenen bir OAuth yapılandırmasıdır.
+func oauthTestConfig(f *fakeOAuthServer, provider string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     provider + "-client",
+		ClientSecret: f.clientSecret,
+		RedirectURL:  "https://api.example.com/" + provider + "/callback",
+		Endpoint:     oauth2.Endpoint{AuthURL: f.URL + "/authorize", TokenURL: f.URL + "/token"},
+	}
+}
+
+// socialLogin, giriş, sağlayıcı onayı ve geri dönüş adımlarını sırayla çalıştırır ve
+// uygulamaya dönülen adresi döndürür.
+func socialLogin(t *testing.T, s *Server, provider OAuthProvider) *url.URL {
+	t.Helper()
+	login := httptest.NewRecorder()
+	s.oauthLoginHandler(provider)(login, httptest.NewRequest(http.MethodGet, "/"+provider.Name()+"/login", nil))
+	if login.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("login: %d %s", login.Code, login.Body)
+	}
+
+	// Tarayıcı gibi sağlayıcının onay sayfasına gidilir; yönlendirme takip edilmez
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(login.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: %d", resp.StatusCode)
+	}
+	callbackURL, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, callbackURL.RequestURI(), nil)
+	for _, cookie := range login.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	callback := httptest.NewRecorder()
+	s.oauthCallbackHandler(provider)(callback, req)
+	if callback.Code != http.StatusFound {
+		t.Fatalf("callback: %d %s", callback.Code, callback.Body)
+	}
+	location, err := url.Parse(callback.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location
+}
+
+func TestGoogleCallbackCreatesAndReusesUser(t *testing.T) {
+	useTestSigningKey(t)
+	s, _ := newTestServer()
+	fake := newFakeOAuthServer(t, "google-secret")
+	provider := newGoogleProvider(oauthTestConfig(fake, "google"), fake.URL+"/userinfo")
+	fake.setProfile(map[string]interface{}{
+		"id": "google-123", "email": " Zeynep@Example.com ", "verified_email": true,
+		"given_name": "Zeynep", "family_name": "Demir",
+	})
+
+	first := socialLogin(t, s, provider)
+	if first.Query().Get("token") == "" || first.Query().Get("refreshToken") == "" || first.Query().Get("type") != "google" {
+		t.Fatalf("uygulamaya token dönmedi: %s", first)
+	}
+	user, err := s.users.FindByIdentity(context.Background(), "google", "google-123")
+	if err != nil {
+		t.Fatalf("Google kullanıcısı oluşturulmadı: %v", err)
+	}
+	if user.Email != "zeynep@example.com" || user.Ad != "Zeynep" || user.Provider != "google" {
+		t.Errorf("kullanıcı bilgileri: %+v", user)
+	}
+
+	// Aynı Google hesabıyla ikinci giriş yeni kullanıcı oluşturmaz
+	socialLogin(t, s, provider)
+	users, total, err := s.users.Search(context.Background(), UserFilter{}, 1, 10)
+	if err != nil || total != 1 || users[0].ID != user.ID {
+		t.Errorf("ikinci girişten sonra %d kullanıcı var, %v", total, err)
+	}
+}
+
+func TestFacebookCallbackUpsert(t *testing.T) {
+	useTestSigningKey(t)
+	s, _ := newTestServer()
+	fake := newFakeOAuthServer(t, "facebook-secret")
+	fake.requireProof = true
+	provider := newFacebookProvider(oauthTestConfig(fake, "facebook"), fake.URL+"/userinfo")
+	existing := createPasswordUser(t, s, "ali@example.com", "Gizli-Parola-42")
+
+	// Facebook e-postayı doğrulamadığı için mevcut şifreli hesaba otomatik bağlanmaz
+	fake.setProfile(map[string]interface{}{"id": "fb-1", "email": "Ali@example.com", "first_name": "Ali"})
+	location := socialLogin(t, s, provider)
+	if location.Query().Get("error") != "account_exists" || location.Query().Get("token") != "" {
+		t.Errorf("mevcut hesap: %s, beklenen account_exists", location)
+	}
+	if stored, _ := s.users.FindByID(context.Background(), existing.ID); len(stored.Identities) != 0 {
+		t.Errorf("Facebook kimliği mevcut hesaba bağlandı: %+v", stored.Identities)
+	}
+
+	// Yeni adresle ilk giriş hesap oluşturur, ikinci giriş aynı hesabı kullanır
+	fake.setProfile(map[string]interface{}{"id": "fb-2", "email": "Elif@Example.com", "first_name": "Elif", "last_name": "Şahin"})
+	if location := socialLogin(t, s, provider); location.Query().Get("token") == "" {
+		t.Fatalf("uygulamaya token dönmedi: %s", location)
+	}
+	user, err := s.users.FindByIdentity(context.Background(), "facebook", "fb-2")
+	if err != nil {
+		t.Fatalf("Facebook kullanıcısı oluşturulmadı: %v", err)
+	}
+	if user.Email != "elif@example.com" || user.Soyad != "Şahin" || user.SocialID != "fb-2" {
+		t.Errorf("kullanıcı bilgileri: %+v", user)
+	}
+	socialLogin(t, s, provider)
+	if _, total, _ := s.users.Search(context.Background(), UserFilter{Provider: "facebook"}, 1, 10); total != 1 {
+		t.Errorf("ikinci girişten sonra %d Facebook kullanıcısı var, beklenen 1", total)
+	}
+
+	// Giriş geçmişine sağlayıcıyla kaydedilir (kayıt arka planda yazılır)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		events, _ := s.loginEvents.ListByUser(context.Background(), user.ID, 10)
+		if len(events) == 2 && events[0].Method == "facebook" && events[0].Success {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("giriş geçmişi: %+v", events)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
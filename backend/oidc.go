@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// OIDC_PROVIDERS_FILE ile verilen JSON dosyası, koda dokunmadan yeni sağlayıcı eklemeyi sağlar:
+//
+//	[
+//	  {
+//	    "name": "microsoft",
+//	    "issuer": "https://login.microsoftonline.com/<tenant>/v2.0",
+//	    "clientId": "...",
+//	    "clientSecretEnv": "MICROSOFT_CLIENT_SECRET",
+//	    "redirectUrl": "https://api.example.com/microsoft/callback",
+//	    "claims": {"email": "preferred_username"}
+//	  }
+//	]
+//
+// Her sağlayıcı /<name>/login ve /<name>/callback adreslerinde yayınlanır.
+
+// OIDCClaimMapping, ID token claim adlarını User alanlarına eşler. Boş alanlarda
+// standart OIDC claim adları kullanılır.
+type OIDCClaimMapping struct {
+	Subject       string `json:"subject"`
+	Email         string `json:"email"`
+	EmailVerified string `json:"emailVerified"`
+	GivenName     string `json:"givenName"`
+	FamilyName    string `json:"familyName"`
+}
+
+// OIDCProviderConfig, yapılandırma dosyasındaki tek bir sağlayıcıdır.
+type OIDCProviderConfig struct {
+	Name            string            `json:"name"`
+	Issuer          string            `json:"issuer"`
+	ClientID        string            `json:"clientId"`
+	ClientSecretEnv string            `json:"clientSecretEnv"` // Gizli anahtar dosyada değil, bu ortam değişkeninde tutulur
+	RedirectURL     string            `json:"redirectUrl"`
+	Scopes          []string          `json:"scopes"`
+	AuthParams      map[string]string `json:"authParams"` // Ör. Apple için {"response_mode": "form_post"}
+	Claims          OIDCClaimMapping  `json:"claims"`
+	// TrustEmailVerified, sağlayıcı email_verified göndermiyorsa e-postanın doğrulanmış sayılıp sayılmayacağıdır
+	TrustEmailVerified bool `json:"trustEmailVerified"`
+}
+
+// oidcDiscovery, /.well-known/openid-configuration yanıtının kullandığımız kısmıdır.
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcProvider struct {
+	cfg    OIDCProviderConfig
+	config *oauth2.Config
+	issuer string
+	keys   KeySource
+}
+
+// newOIDCProvider, sağlayıcının keşif belgesini okur ve bir OAuthProvider döndürür.
+func newOIDCProvider(ctx context.Context, cfg OIDCProviderConfig, client *http.Client) (OAuthProvider, error) {
+	if cfg.Name == "" || cfg.Issuer == "" || cfg.ClientID == "" {
+		return nil, errors.New("name, issuer ve clientId zorunludur")
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	discoveryURL := strings.TrimSuffix(cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: beklenmeyen durum kodu %d", discoveryURL, resp.StatusCode)
+	}
+
+	var doc oidcDiscovery
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, err
+	}
+	// Keşif belgesindeki issuer yapılandırılanla birebir aynı olmalıdır (OIDC Discovery 4.3)
+	if doc.Issuer != cfg.Issuer {
+		return nil, fmt.Errorf("keşif belgesindeki issuer %q, beklenen %q", doc.Issuer, cfg.Issuer)
+	}
+
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+
+	return &oidcProvider{
+		cfg: cfg,
+		config: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: os.Getenv(cfg.ClientSecretEnv),
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       scopes,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  doc.AuthorizationEndpoint,
+				TokenURL: doc.TokenEndpoint,
+			},
+		},
+		issuer: doc.Issuer,
+		keys:   NewRemoteKeySet(doc.JWKSURI, client),
+	}, nil
+}
+
+func (p *oidcProvider) Name() string                { return p.cfg.Name }
+func (p *oidcProvider) OAuthConfig() *oauth2.Config { return p.config }
+
+func (p *oidcProvider) AuthCodeOptions(nonce string) []oauth2.AuthCodeOption {
+	opts := []oauth2.AuthCodeOption{oauth2.SetAuthURLParam("nonce", nonce)}
+	for k, v := range p.cfg.AuthParams {
+		opts = append(opts, oauth2.SetAuthURLParam(k, v))
+	}
+	return opts
+}
+
+func (p *oidcProvider) FetchProfile(ctx context.Context, token *oauth2.Token, nonce string) (*SocialProfile, error) {
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("token yanıtında id_token yok")
+	}
+
+	claims, err := verifyIDToken(ctx, rawIDToken, p.keys, IDTokenExpectations{
+		Issuers:   []string{p.issuer},
+		Audiences: []string{p.cfg.ClientID},
+		Nonce:     nonce,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	mapping := p.cfg.Claims
+	str := func(name string) string {
+		v, _ := claims[name].(string)
+		return v
+	}
+
+	profile := &SocialProfile{
+		Subject:    str(firstNonEmpty(mapping.Subject, "sub")),
+		Email:      normalizeEmail(str(firstNonEmpty(mapping.Email, "email"))),
+		GivenName:  str(firstNonEmpty(mapping.GivenName, "given_name")),
+		FamilyName: str(firstNonEmpty(mapping.FamilyName, "family_name")),
+	}
+	if verified, present := claims[firstNonEmpty(mapping.EmailVerified, "email_verified")]; present {
+		profile.EmailVerified = claimBool(verified)
+	} else {
+		profile.EmailVerified = p.cfg.TrustEmailVerified
+	}
+	if profile.Subject == "" {
+		return nil, errors.New("ID token'da sub yok")
+	}
+	return profile, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// InitOIDCProviders, OIDC_PROVIDERS_FILE dosyasındaki sağlayıcıları yükler. Keşif belgesine
+// ulaşılamayan sağlayıcılar atlanır; böylece tek bir kimlik sağlayıcısının arızası sunucunun
+// açılmasını engellemez.
+func InitOIDCProviders() []OAuthProvider {
+	path := os.Getenv("OIDC_PROVIDERS_FILE")
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal("OIDC yapılandırması okunamadı:", err)
+	}
+	var configs []OIDCProviderConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		log.Fatal("OIDC yapılandırması çözülemedi:", err)
+	}
+
+	reserved := map[string]bool{googleLogin.Name(): true, facebookLogin.Name(): true}
+	var providers []OAuthProvider
+	for _, cfg := range configs {
+		if reserved[cfg.Name] {
+			log.Fatalf("OIDC sağlayıcı adı %q zaten kullanılıyor", cfg.Name)
+		}
+		reserved[cfg.Name] = true
+
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		provider, err := newOIDCProvider(ctx, cfg, nil)
+		cancel()
+		if err != nil {
+			log.Printf("OIDC sağlayıcısı %q yüklenemedi: %v", cfg.Name, err)
+			continue
+		}
+		providers = append(providers, provider)
+		log.Printf("OIDC sağlayıcısı yüklendi: %s", cfg.Name)
+	}
+	return providers
+}
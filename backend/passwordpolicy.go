@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Uygulamayla birlikte gelen yaygın şifre listesi. Sızdırılmış şifre listeleri gibi daha büyük
+// bir liste PASSWORD_BLOCKLIST_FILE ile eklenebilir; her satırda bir şifre bulunur.
+//
+//go:embed passwords/common.txt
+var commonPasswords []byte
+
+// bcrypt 72 bayttan sonrasını yok sayar; daha uzun şifreler sessizce kısaltılmasın diye reddedilir.
+const maxPasswordBytes = 72
+
+// PasswordPolicy, şifre kurallarıdır. Değerler ortam değişkenlerinden okunur.
+type PasswordPolicy struct {
+	MinLength       int  // PASSWORD_MIN_LENGTH, varsayılan 8
+	BanPersonalInfo bool // PASSWORD_ALLOW_PERSONAL_INFO=true ile kapatılır
+	CheckBreached   bool // PASSWORD_SKIP_BLOCKLIST=true ile kapatılır
+	blocklist       *bloomFilter
+}
+
+// PasswordContext, şifrede bulunmaması gereken kullanıcı bilgileridir.
+type PasswordContext struct {
+	Email string
+	Ad    string
+	Soyad string
+}
+
+var passwordPolicy = &PasswordPolicy{MinLength: 8, BanPersonalInfo: true, CheckBreached: true}
+
+// InitPasswordPolicy, politikayı ortam değişkenlerine göre ayarlar ve engel listesini yükler.
+func InitPasswordPolicy() {
+	if v := os.Getenv("PASSWORD_MIN_LENGTH"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			log.Fatalf("PASSWORD_MIN_LENGTH geçersiz: %q", v)
+		}
+		passwordPolicy.MinLength = n
+	}
+	passwordPolicy.BanPersonalInfo = os.Getenv("PASSWORD_ALLOW_PERSONAL_INFO") != "true"
+	passwordPolicy.CheckBreached = os.Getenv("PASSWORD_SKIP_BLOCKLIST") != "true"
+	if !passwordPolicy.CheckBreached {
+		return
+	}
+
+	sources := []io.Reader{bytes.NewReader(commonPasswords)}
+	if path := os.Getenv("PASSWORD_BLOCKLIST_FILE"); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatal("Şifre engel listesi açılamadı:", err)
+		}
+		defer f.Close()
+		sources = append(sources, f)
+	}
+
+	var entries []string
+	for _, src := range sources {
+		scanner := bufio.NewScanner(src)
+		for scanner.Scan() {
+			if line := normalizePassword(scanner.Text()); line != "" {
+				entries = append(entries, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			log.Fatal("Şifre engel listesi okunamadı:", err)
+		}
+	}
+
+	filter := newBloomFilter(len(entries), 0.001)
+	for _, entry := range entries {
+		filter.Add(entry)
+	}
+	passwordPolicy.blocklist = filter
+	log.Printf("Şifre engel listesi yüklendi: %d kayıt", len(entries))
+}
+
+func normalizePassword(password string) string {
+	return strings.ToLower(strings.TrimSpace(password))
+}
+
+// Validate, şifreyi politikaya göre denetler ve ihlalleri verilen alan adıyla döndürür.
+// Boş liste şifrenin kabul edildiği anlamına gelir.
+func (p *PasswordPolicy) Validate(field, password string, info PasswordContext) []FieldError {
+	var errs []FieldError
+	add := func(code, message string) {
+		errs = append(errs, FieldError{Field: field, Code: code, Message: message})
+	}
+
+	if password == "" {
+		add("required", "Şifre boş olamaz")
+		return errs
+	}
+	if utf8.RuneCountInString(password) < p.MinLength {
+		add("too_short", fmt.Sprintf("Şifre en az %d karakter olmalı", p.MinLength))
+	}
+	if len(password) > maxPasswordBytes {
+		add("too_long", fmt.Sprintf("Şifre en fazla %d bayt olabilir", maxPasswordBytes))
+	}
+
+	normalized := normalizePassword(password)
+	if p.BanPersonalInfo && containsPersonalInfo(normalized, info) {
+		add("contains_personal_info", "Şifre adınızı, soyadınızı veya e-posta adresinizi içeremez")
+	}
+	if p.CheckBreached && p.blocklist != nil && p.blocklist.Contains(normalized) {
+		add("common_password", "Bu şifre çok yaygın veya sızdırılmış şifre listelerinde bulunuyor")
+	}
+	return errs
+}
+
+// containsPersonalInfo, şifrenin ad, soyad, e-posta veya e-postanın kullanıcı adı kısmını
+// içerip içermediğini kontrol eder. Çok kısa parçalar yanlış alarm vermesin diye atlanır.
+func containsPersonalInfo(normalized string, info PasswordContext) bool {
+	email := strings.ToLower(strings.TrimSpace(info.Email))
+	local := email
+	if at := strings.IndexByte(email, '@'); at >= 0 {
+		local = email[:at]
+	}
+	for _, part := range []string{email, local, info.Ad, info.Soyad} {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if utf8.RuneCountInString(part) >= 3 && strings.Contains(normalized, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeValidationErrors, alan bazlı doğrulama hatalarını 400 ile JSON olarak döndürür.
+func writeValidationErrors(w http.ResponseWriter, fields []FieldError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(ValidationErrorResponse{
+		Error:  "Girilen bilgiler geçersiz",
+		Fields: fields,
+	})
+}
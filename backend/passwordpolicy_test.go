@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	blocklist := newBloomFilter(2, 0.001)
+	blocklist.Add(normalizePassword("Sifre12345"))
+	blocklist.Add(normalizePassword("galatasaray1905"))
+	policy := &PasswordPolicy{MinLength: 8, BanPersonalInfo: true, CheckBreached: true, blocklist: blocklist}
+	info := PasswordContext{Email: "Ayse.Yilmaz@example.com", Ad: "Ayşe", Soyad: "Yılmaz"}
+
+	tests := []struct {
+		name     string
+		password string
+		want     []string // Beklenen hata kodları, sırasıyla
+	}{
+		{name: "geçerli", password: "Mavi-Deniz-42"},
+		{name: "boş", password: "", want: []string{"required"}},
+		{name: "kısa", password: "Kus-7", want: []string{"too_short"}},
+		{name: "çok baytlı karakterler rune olarak sayılır", password: "ğüşöçığü"},
+		{name: "72 bayt sınırda", password: strings.Repeat("a", 70) + "-7"},
+		{name: "72 bayttan uzun", password: strings.Repeat("a", 71) + "-7", want: []string{"too_long"}},
+		{name: "72 bayttan uzun çok baytlı", password: strings.Repeat("ş", 37), want: []string{"too_long"}},
+		{name: "ad", password: "ayşe-2024!", want: []string{"contains_personal_info"}},
+		{name: "soyad", password: "Parola-Yılmaz", want: []string{"contains_personal_info"}},
+		{name: "e-posta kullanıcı adı", password: "x-ayse.yilmaz-x", want: []string{"contains_personal_info"}},
+		{name: "engel listesinde", password: "Sifre12345", want: []string{"common_password"}},
+		{name: "engel listesinde boşluklu", password: "  GALATASARAY1905 ", want: []string{"common_password"}},
+		{name: "birden fazla ihlal", password: "Ayşe", want: []string{"too_short", "contains_personal_info"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := policy.Validate("sifre", tc.password, info)
+			var got []string
+			for _, e := range errs {
+				if e.Field != "sifre" || e.Message == "" {
+					t.Errorf("eksik hata bilgisi: %+v", e)
+				}
+				got = append(got, e.Code)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Errorf("Validate(%q) = %v, beklenen %v", tc.password, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyValidateDisabledChecks(t *testing.T) {
+	blocklist := newBloomFilter(1, 0.001)
+	blocklist.Add(normalizePassword("Sifre12345"))
+	policy := &PasswordPolicy{MinLength: 4, blocklist: blocklist}
+	info := PasswordContext{Ad: "Ayşe"}
+
+	for _, password := range []string{"Sifre12345", "ayşe-2024", "Kus7"} {
+		if errs := policy.Validate("sifre", password, info); len(errs) != 0 {
+			t.Errorf("Validate(%q) = %+v, kapalı kurallar uygulandı", password, errs)
+		}
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	const n, rate = 5000, 0.01
+	filter := newBloomFilter(n, rate)
+	for i := 0; i < n; i++ {
+		filter.Add(fmt.Sprintf("eklenen-%d", i))
+	}
+
+	// Eklenen hiçbir öğe için yanlış negatif olmamalı
+	for i := 0; i < n; i++ {
+		if item := fmt.Sprintf("eklenen-%d", i); !filter.Contains(item) {
+			t.Fatalf("eklenen öğe bulunamadı: %s", item)
+		}
+	}
+
+	// Yanlış pozitif oranı hedefin makul bir katını aşmamalı
+	const probes = 20000
+	falsePositives := 0
+	for i := 0; i < probes; i++ {
+		if filter.Contains(fmt.Sprintf("eklenmeyen-%d", i)) {
+			falsePositives++
+		}
+	}
+	if got := float64(falsePositives) / probes; got > 2*rate {
+		t.Errorf("yanlış pozitif oranı %.4f, hedef %.4f", got, rate)
+	}
+}
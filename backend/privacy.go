@@ -0,0 +1,371 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	accountDeletionGrace = 30 * 24 * time.Hour // Silinen hesap bu süre sonunda kalıcı olarak temizlenir
+	accountPurgeInterval = time.Hour
+	deleteAccountCodeTTL = 10 * time.Minute // Şifresiz hesaplara silme onayı için gönderilen kodun ömrü
+)
+
+var errAccountDeleted = errors.New("hesap silinme sürecinde")
+
+// userDataSource, kullanıcıya ait kayıt tutan bir koleksiyondur. Dışa aktarma ve kalıcı
+// silme bu listeyi kullanır; kullanıcıyı referans eden yeni bir koleksiyon eklendiğinde
+// buraya da eklenmelidir. Doğrulama kodları koleksiyonla değil VerificationStore ile
+// okunup silinir.
+type userDataSource struct {
+	Name       string
+	Collection func() *mongo.Collection
+	Filter     func(user *User) bson.M
+}
+
+func byUserID(field string) func(user *User) bson.M {
+	return func(user *User) bson.M { return bson.M{field: user.ID} }
+}
+
+var userDataSources = []userDataSource{
+	{"sessions", func() *mongo.Collection { return database.Collection("sessions") }, byUserID("userId")},
+	{"webauthn_credentials", func() *mongo.Collection { return webauthnCredentialsCollection }, byUserID("userId")},
+	{"webauthn_sessions", func() *mongo.Collection { return webauthnSessionsCollection }, byUserID("userId")},
+	{"magic_links", func() *mongo.Collection { return magicLinksCollection }, byUserID("userId")},
+	{"email_changes", func() *mongo.Collection { return emailChangesCollection }, byUserID("userId")},
+	{"login_events", func() *mongo.Collection { return database.Collection("login_events") }, byUserID("userId")},
+	{"login_failures", func() *mongo.Collection { return database.Collection("login_failures") }, func(user *User) bson.M {
+		return bson.M{"_id": accountLockoutKey(user.ID)}
+	}},
+	{"oauth_states", func() *mongo.Collection { return database.Collection("oauth_states") }, byUserID("linkUserId")},
+}
+
+// exportRedactedFields, dışa aktarmada yer almayan gizli alanlardır. Bunlar kişisel veri
+// değil, hesabı ele geçirmeye yarayabilecek sırlardır.
+var exportRedactedFields = []string{
+	"sifre", "totpSecret", "totpPendingSecret", "recoveryCodes",
+	"tokenHash", "codeHash", "undoTokenHash", "unlockTokenHash", "accessJti", "stateHash", "codeVerifier", "nonce", "data",
+}
+
+// exportUserData, kullanıcının tüm kayıtlarını koleksiyon başına bir JSON dosyası olarak
+// ZIP arşivine yazar.
+func (s *Server) exportUserData(ctx context.Context, user *User) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	files := []string{}
+	write := func(name string, docs []bson.M) error {
+		for _, doc := range docs {
+			for _, field := range exportRedactedFields {
+				delete(doc, field)
+			}
+		}
+		items := make([]string, 0, len(docs))
+		for _, doc := range docs {
+			data, err := bson.MarshalExtJSON(doc, false, false)
+			if err != nil {
+				return err
+			}
+			items = append(items, string(data))
+		}
+		var out bytes.Buffer
+		if err := json.Indent(&out, []byte("["+strings.Join(items, ",")+"]"), "", "  "); err != nil {
+			return err
+		}
+		f, err := archive.Create(name + ".json")
+		if err != nil {
+			return err
+		}
+		files = append(files, name+".json")
+		_, err = f.Write(out.Bytes())
+		return err
+	}
+
+	toDoc := func(v interface{}) (bson.M, error) {
+		raw, err := bson.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		var doc bson.M
+		err = bson.Unmarshal(raw, &doc)
+		return doc, err
+	}
+
+	stored, err := s.users.FindByID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := toDoc(stored)
+	if err != nil {
+		return nil, err
+	}
+	if err := write("user", []bson.M{profile}); err != nil {
+		return nil, err
+	}
+
+	codes, err := s.codes.ListByEmail(ctx, user.Email)
+	if err != nil {
+		return nil, err
+	}
+	codeDocs := make([]bson.M, 0, len(codes))
+	for _, code := range codes {
+		doc, err := toDoc(code)
+		if err != nil {
+			return nil, err
+		}
+		codeDocs = append(codeDocs, doc)
+	}
+	if err := write("verification_codes", codeDocs); err != nil {
+		return nil, err
+	}
+
+	for _, source := range userDataSources {
+		cursor, err := source.Collection().Find(ctx, source.Filter(user))
+		if err != nil {
+			return nil, err
+		}
+		var docs []bson.M
+		if err := cursor.All(ctx, &docs); err != nil {
+			return nil, err
+		}
+		if err := write(source.Name, docs); err != nil {
+			return nil, err
+		}
+	}
+
+	manifest, err := json.MarshalIndent(map[string]interface{}{
+		"userId":     user.ID.Hex(),
+		"exportedAt": time.Now().UTC(),
+		"files":      files,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	f, err := archive.Create("manifest.json")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(manifest); err != nil {
+		return nil, err
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// exportUserDataHandler, kullanıcının verilerini ZIP olarak indirir (KVKK md. 11, GDPR md. 15 ve 20).
+func (s *Server) exportUserDataHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims, ok := s.authenticateRequest(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := s.getUserByClaims(claims)
+	if err != nil {
+		http.Error(w, `{"error": "Kullanıcı bulunamadı"}`, http.StatusNotFound)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	archive, err := s.exportUserData(ctx, user)
+	if err != nil {
+		log.Printf("Veri dışa aktarma hatası: %v", err)
+		http.Error(w, `{"error": "Veriler dışa aktarılamadı"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="eventra-verilerim-%s.zip"`, time.Now().Format("2006-01-02")))
+	w.WriteHeader(http.StatusOK)
+	w.Write(archive)
+}
+
+// sendDeleteAccountCodeHandler, şifresiz hesaplara hesap silme onayı için tek kullanımlık
+// bir kod gönderir. Token yenilenerek taze tutulabildiği için bu hesaplarda token yaşı kimlik
+// kanıtı sayılmaz; e-posta kutusuna erişim ayrıca gösterilmelidir.
+func (s *Server) sendDeleteAccountCodeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims, ok := s.authenticateRequest(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := s.getUserByClaims(claims)
+	if err != nil {
+		http.Error(w, `{"error": "Kullanıcı bulunamadı"}`, http.StatusNotFound)
+		return
+	}
+	if user.Sifre != "" {
+		http.Error(w, `{"error": "Hesabınızda şifre var, silme işlemini şifrenizle onaylayın"}`, http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	code, err := s.issueVerificationCode(ctx, CodePurposeDelete, user.Email, deleteAccountCodeTTL)
+	if cooldown, ok := err.(*CodeCooldownError); ok {
+		writeRetryAfter(w, cooldown.RetryAfter)
+		http.Error(w, `{"error": "Yeni kod istemeden önce lütfen bekleyin"}`, http.StatusTooManyRequests)
+		return
+	} else if err != nil {
+		log.Printf("Doğrulama kodu kaydetme hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+
+	body := fmt.Sprintf("Merhaba %s,\n\nHesap silme onay kodunuz: %s\n\nBu kod 10 dakika içinde geçerliliğini yitirecektir. Hesabınızı silmek isteyen siz değilseniz lütfen bağlı hesaplarınızın güvenliğini kontrol edin.", user.Ad, code)
+	if err := s.sendEmail(user.Email, "Hesap Silme Onay Kodunuz", body); err != nil {
+		log.Printf("E-posta gönderme hatası: %v", err)
+		http.Error(w, `{"error": "E-posta gönderme başarısız"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MessageResponse{Message: "Hesap silme onay kodu e-posta adresinize gönderildi"})
+}
+
+// reauthenticate, hassas işlemlerden önce kullanıcının kimliğini yeniden doğrular. Şifreli
+// hesaplarda şifre, şifresiz hesaplarda e-postayla gönderilen tek kullanımlık kod gerekir.
+// 2FA açıksa ayrıca geçerli bir kod istenir.
+func (s *Server) reauthenticate(ctx context.Context, user *User, req *DeleteAccountRequest) bool {
+	if user.Sifre != "" {
+		if !checkPasswordHash(req.Sifre, user.Sifre) {
+			return false
+		}
+	} else if req.EmailCode == "" {
+		return false
+	} else if err := s.consumeVerificationCode(ctx, CodePurposeDelete, user.Email, req.EmailCode); err != nil {
+		if err != errCodeInvalid && err != errCodeTooManyAttempts {
+			log.Printf("Doğrulama kodu hatası: %v", err)
+		}
+		return false
+	}
+
+	if user.TOTPEnabled {
+		valid, err := s.checkSecondFactor(ctx, user, req.Code, req.RecoveryCode)
+		if err != nil {
+			log.Printf("2FA doğrulama hatası: %v", err)
+		}
+		return valid
+	}
+	return true
+}
+
+// deleteAccountHandler, hesabı hemen kullanıma kapatır ve kalıcı silmeyi zamanlar.
+func (s *Server) deleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims, ok := s.authenticateRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Geçersiz JSON formatı"}`, http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.getUserByClaims(claims)
+	if err != nil {
+		http.Error(w, `{"error": "Kullanıcı bulunamadı"}`, http.StatusNotFound)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if !s.reauthenticate(ctx, user, &req) {
+		http.Error(w, `{"error": "Kimlik doğrulaması başarısız, lütfen tekrar giriş yapın"}`, http.StatusUnauthorized)
+		return
+	}
+
+	now := time.Now()
+	purgeAt := now.Add(accountDeletionGrace)
+	err = s.users.MarkDeleted(ctx, user.ID, now, purgeAt)
+	if err != nil {
+		log.Printf("Hesap silme hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.revokeAllUserTokens(ctx, user.ID); err != nil {
+		log.Printf("Oturum iptal hatası: %v", err)
+	}
+	if err := s.revokeToken(ctx, claims.Id, time.Unix(claims.ExpiresAt, 0)); err != nil {
+		log.Printf("Token iptal hatası: %v", err)
+	}
+
+	go func() {
+		body := fmt.Sprintf("Merhaba %s,\n\nHesabınız silinmek üzere kapatıldı. Tüm verileriniz %s tarihinde kalıcı olarak silinecek.\n\nBu işlemi siz yapmadıysanız lütfen bu tarihten önce bizimle iletişime geçin.", user.Ad, purgeAt.Format("02.01.2006"))
+		if err := s.sendEmail(user.Email, "Hesabınız silinmek üzere kapatıldı", body); err != nil {
+			log.Printf("E-posta gönderim hatası (asenkron): %v", err)
+		}
+	}()
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Hesabınız kapatıldı ve kalıcı silme için sıraya alındı",
+		"purgeAt": purgeAt,
+	})
+}
+
+// purgeUser, kullanıcıyı ve onu referans eden tüm kayıtları kalıcı olarak siler.
+func (s *Server) purgeUser(ctx context.Context, user *User) error {
+	for _, source := range userDataSources {
+		if _, err := source.Collection().DeleteMany(ctx, source.Filter(user)); err != nil {
+			return fmt.Errorf("%s: %w", source.Name, err)
+		}
+	}
+	if err := s.codes.DeleteByEmail(ctx, user.Email); err != nil {
+		return fmt.Errorf("verification_codes: %w", err)
+	}
+	return s.users.Purge(ctx, user.ID, time.Now())
+}
+
+// purgeDeletedAccounts, bekleme süresi dolmuş hesapları temizler.
+func (s *Server) purgeDeletedAccounts(ctx context.Context) {
+	users, err := s.users.ListPurgeable(ctx, time.Now())
+	if err != nil {
+		log.Printf("Hesap temizleme hatası: %v", err)
+		return
+	}
+	for i := range users {
+		if err := s.purgeUser(ctx, &users[i]); err != nil {
+			log.Printf("Hesap temizleme hatası (%s): %v", users[i].ID.Hex(), err)
+			continue
+		}
+		log.Printf("Hesap kalıcı olarak silindi: %s", users[i].ID.Hex())
+	}
+}
+
+// StartAccountPurger, silinmiş hesapları düzenli aralıklarla temizleyen arka plan işini başlatır.
+func (s *Server) StartAccountPurger() {
+	go func() {
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+			s.purgeDeletedAccounts(ctx)
+			cancel()
+			time.Sleep(accountPurgeInterval)
+		}
+	}()
+}
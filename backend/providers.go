@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/facebook"
+	"golang.org/x/oauth2/google"
+)
+
+// Sosyal giriş sağlayıcıları
+var (
+	googleLogin   OAuthProvider
+	facebookLogin OAuthProvider
+)
+
+func init() {
+	// Google OAuth2 yapılandırması
+	googleLogin = newGoogleProvider(&oauth2.Config{
+		ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
+		ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("GOOGLE_REDIRECT_URL"),
+		Scopes:       []string{"https://www.googleapis.com/auth/userinfo.email", "https://www.googleapis.com/auth/userinfo.profile"},
+		Endpoint:     google.Endpoint,
+	}, "https://www.googleapis.com/oauth2/v2/userinfo")
+
+	// Facebook OAuth2 yapılandırması
+	facebookLogin = newFacebookProvider(&oauth2.Config{
+		ClientID:     os.Getenv("FACEBOOK_CLIENT_ID"),
+		ClientSecret: os.Getenv("FACEBOOK_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("FACEBOOK_REDIRECT_URL"),
+		Scopes:       []string{"email", "public_profile"},
+		Endpoint:     facebook.Endpoint,
+	}, "https://graph.facebook.com/v19.0/me")
+}
+
+// getJSON, OAuth token'ı ile yetkilendirilmiş bir GET isteği yapar ve yanıtı çözer.
+func getJSON(ctx context.Context, config *oauth2.Config, token *oauth2.Token, endpoint string, out interface{}) error {
+	resp, err := config.Client(ctx, token).Get(endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: beklenmeyen durum kodu %d", endpoint, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+type googleProvider struct {
+	config      *oauth2.Config
+	userInfoURL string
+}
+
+// newGoogleProvider, verilen yapılandırma ve userinfo adresiyle Google sağlayıcısı oluşturur.
+func newGoogleProvider(config *oauth2.Config, userInfoURL string) OAuthProvider {
+	return &googleProvider{config: config, userInfoURL: userInfoURL}
+}
+
+func (p *googleProvider) Name() string                { return "google" }
+func (p *googleProvider) OAuthConfig() *oauth2.Config { return p.config }
+
+func (p *googleProvider) AuthCodeOptions(nonce string) []oauth2.AuthCodeOption {
+	return []oauth2.AuthCodeOption{oauth2.AccessTypeOffline, oauth2.ApprovalForce}
+}
+
+func (p *googleProvider) FetchProfile(ctx context.Context, token *oauth2.Token, nonce string) (*SocialProfile, error) {
+	var googleUser GoogleUser
+	if err := getJSON(ctx, p.config, token, p.userInfoURL, &googleUser); err != nil {
+		return nil, err
+	}
+	return &SocialProfile{
+		Subject:       googleUser.ID,
+		Email:         normalizeEmail(googleUser.Email),
+		EmailVerified: googleUser.VerifiedEmail,
+		GivenName:     googleUser.GivenName,
+		FamilyName:    googleUser.FamilyName,
+	}, nil
+}
+
+type facebookProvider struct {
+	config *oauth2.Config
+	meURL  string
+}
+
+// newFacebookProvider, verilen yapılandırma ve Graph API "/me" adresiyle Facebook sağlayıcısı oluşturur.
+func newFacebookProvider(config *oauth2.Config, meURL string) OAuthProvider {
+	return &facebookProvider{config: config, meURL: meURL}
+}
+
+func (p *facebookProvider) Name() string                { return "facebook" }
+func (p *facebookProvider) OAuthConfig() *oauth2.Config { return p.config }
+
+func (p *facebookProvider) AuthCodeOptions(nonce string) []oauth2.AuthCodeOption {
+	return nil
+}
+
+func (p *facebookProvider) FetchProfile(ctx context.Context, token *oauth2.Token, nonce string) (*SocialProfile, error) {
+	// appsecret_proof, çalınmış bir access token'ın başka bir uygulamadan kullanılmasını engeller
+	mac := hmac.New(sha256.New, []byte(p.config.ClientSecret))
+	mac.Write([]byte(token.AccessToken))
+
+	query := url.Values{}
+	query.Set("fields", "id,email,first_name,last_name")
+	query.Set("appsecret_proof", hex.EncodeToString(mac.Sum(nil)))
+
+	var facebookUser FacebookUser
+	if err := getJSON(ctx, p.config, token, p.meURL+"?"+query.Encode(), &facebookUser); err != nil {
+		return nil, err
+	}
+	return &SocialProfile{
+		Subject: facebookUser.ID,
+		Email:   normalizeEmail(facebookUser.Email),
+		// Graph API e-postanın doğrulandığını garanti etmez; mevcut bir hesaba otomatik
+		// bağlanmaz, kullanıcı Facebook'u oturum açıkken /user/identities/facebook ile bağlamalıdır
+		EmailVerified: false,
+		GivenName:     facebookUser.FirstName,
+		FamilyName:    facebookUser.LastName,
+	}, nil
+}
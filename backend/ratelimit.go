@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RateLimit, bir token bucket tanımıdır: en fazla Burst istek art arda geçebilir,
+// ardından her Interval'da bir token geri gelir. Sıfır değer sınırsız demektir.
+type RateLimit struct {
+	Burst    int
+	Interval time.Duration
+}
+
+func (l RateLimit) enabled() bool {
+	return l.Burst > 0 && l.Interval > 0
+}
+
+// RouteLimits, bir endpoint için IP ve e-posta bazlı sınırlardır.
+type RouteLimits struct {
+	PerIP    RateLimit
+	PerEmail RateLimit // İstek gövdesindeki "email" alanına göre uygulanır
+}
+
+// Auth endpoint'leri için sınırlar
+var (
+	sendCodeLimits = RouteLimits{
+		PerIP:    RateLimit{Burst: 10, Interval: time.Minute},
+		PerEmail: RateLimit{Burst: 3, Interval: 5 * time.Minute},
+	}
+	loginLimits = RouteLimits{
+		PerIP:    RateLimit{Burst: 20, Interval: 30 * time.Second},
+		PerEmail: RateLimit{Burst: 5, Interval: time.Minute},
+	}
+	resetCodeLimits = RouteLimits{
+		PerIP:    RateLimit{Burst: 10, Interval: time.Minute},
+		PerEmail: RateLimit{Burst: 3, Interval: 5 * time.Minute},
+	}
+	resetPasswordLimits = RouteLimits{
+		PerIP:    RateLimit{Burst: 10, Interval: time.Minute},
+		PerEmail: RateLimit{Burst: 5, Interval: 2 * time.Minute},
+	}
+	magicLinkLimits = RouteLimits{
+		PerIP:    RateLimit{Burst: 10, Interval: time.Minute},
+		PerEmail: RateLimit{Burst: 3, Interval: 5 * time.Minute},
+	}
+	exportLimits = RouteLimits{
+		PerIP: RateLimit{Burst: 3, Interval: 10 * time.Minute},
+	}
+	socialTokenLimits = RouteLimits{
+		PerIP: RateLimit{Burst: 20, Interval: 30 * time.Second},
+	}
+)
+
+// RateLimitStore, kova durumlarını saklayan arka uçtur. Take bir token tüketmeyi dener;
+// kova boşsa false ve bir sonraki token'a kalan süreyi döndürür.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit RateLimit, now time.Time) (bool, time.Duration, error)
+}
+
+var rateLimitStore RateLimitStore
+
+// InitRateLimiter, RATE_LIMIT_BACKEND değişkenine göre arka ucu seçer. Birden fazla
+// instance çalıştığında sınırların ortak olması için "mongo" kullanılmalıdır.
+func InitRateLimiter() {
+	switch os.Getenv("RATE_LIMIT_BACKEND") {
+	case "mongo":
+		rateLimitStore = &mongoRateLimitStore{collection: database.Collection("rate_limits")}
+	case "", "memory":
+		rateLimitStore = newMemoryRateLimitStore()
+	default:
+		log.Fatal("Geçersiz RATE_LIMIT_BACKEND değeri: ", os.Getenv("RATE_LIMIT_BACKEND"))
+	}
+}
+
+// refill, kovanın son güncellemeden bu yana dolan token'larını hesaplar ve bir token tüketmeyi dener.
+func refill(tokens float64, updatedAt time.Time, limit RateLimit, now time.Time) (float64, bool, time.Duration) {
+	elapsed := now.Sub(updatedAt)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	tokens += float64(elapsed) / float64(limit.Interval)
+	if tokens > float64(limit.Burst) {
+		tokens = float64(limit.Burst)
+	}
+	if tokens < 1 {
+		return tokens, false, time.Duration((1 - tokens) * float64(limit.Interval))
+	}
+	return tokens - 1, true, 0
+}
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// memoryRateLimitStore, tek instance ve yerel geliştirme için süreç içi arka uçtur.
+type memoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	sweptAt time.Time
+}
+
+func newMemoryRateLimitStore() *memoryRateLimitStore {
+	return &memoryRateLimitStore{buckets: map[string]*bucket{}}
+}
+
+func (s *memoryRateLimitStore) Take(ctx context.Context, key string, limit RateLimit, now time.Time) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Uzun süredir dokunulmayan kovalar zaten dolmuştur; belleği temiz tutmak için silinir
+	if now.Sub(s.sweptAt) > 10*time.Minute {
+		for k, b := range s.buckets {
+			if now.Sub(b.updatedAt) > time.Hour {
+				delete(s.buckets, k)
+			}
+		}
+		s.sweptAt = now
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updatedAt: now}
+		s.buckets[key] = b
+	}
+
+	tokens, allowed, retryAfter := refill(b.tokens, b.updatedAt, limit, now)
+	b.tokens = tokens
+	b.updatedAt = now
+	return allowed, retryAfter, nil
+}
+
+// mongoRateLimitStore, kovaları "rate_limits" koleksiyonunda tutar. Eşzamanlı güncellemeler
+// updatedAt üzerinden iyimser kilitleme ile çözülür.
+type mongoRateLimitStore struct {
+	collection *mongo.Collection
+}
+
+type rateLimitDocument struct {
+	Key       string    `bson:"_id"`
+	Tokens    float64   `bson:"tokens"`
+	UpdatedAt time.Time `bson:"updatedAt"`
+}
+
+func (s *mongoRateLimitStore) Take(ctx context.Context, key string, limit RateLimit, now time.Time) (bool, time.Duration, error) {
+	for attempt := 0; attempt < 5; attempt++ {
+		var doc rateLimitDocument
+		err := s.collection.FindOne(ctx, bson.M{"_id": key}).Decode(&doc)
+		if err == mongo.ErrNoDocuments {
+			tokens, allowed, retryAfter := refill(float64(limit.Burst), now, limit, now)
+			_, err = s.collection.InsertOne(ctx, rateLimitDocument{Key: key, Tokens: tokens, UpdatedAt: now})
+			if mongo.IsDuplicateKeyError(err) {
+				continue // Başka bir istek aynı anda oluşturdu, tekrar dene
+			}
+			if err != nil {
+				return false, 0, err
+			}
+			return allowed, retryAfter, nil
+		} else if err != nil {
+			return false, 0, err
+		}
+
+		tokens, allowed, retryAfter := refill(doc.Tokens, doc.UpdatedAt, limit, now)
+		result, err := s.collection.UpdateOne(
+			ctx,
+			bson.M{"_id": key, "updatedAt": doc.UpdatedAt},
+			bson.M{"$set": bson.M{"tokens": tokens, "updatedAt": now}},
+		)
+		if err != nil {
+			return false, 0, err
+		}
+		if result.MatchedCount == 1 {
+			return allowed, retryAfter, nil
+		}
+	}
+	// Yoğun çakışma: isteği reddetmek yerine kısa bir bekleme öner
+	return false, time.Second, nil
+}
+
+// emailFromBody, JSON gövdesindeki "email" alanını okur ve gövdeyi handler için geri yükler.
+func emailFromBody(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	var payload struct {
+		Email string `json:"email"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return ""
+	}
+	return normalizeEmail(payload.Email)
+}
+
+type rateLimitCheck struct {
+	key   string
+	limit RateLimit
+}
+
+// rateLimit, route'a IP ve e-posta bazlı sınır uygulayan bir mux middleware'i döndürür.
+// Sınır aşıldığında 429 ve Retry-After başlığı ile yanıt verilir.
+func rateLimit(route string, limits RouteLimits) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+			defer cancel()
+			now := time.Now()
+
+			checks := []rateLimitCheck{{"rl:" + route + ":ip:" + clientIP(r), limits.PerIP}}
+			if limits.PerEmail.enabled() {
+				if email := emailFromBody(r); email != "" {
+					checks = append(checks, rateLimitCheck{"rl:" + route + ":email:" + email, limits.PerEmail})
+				}
+			}
+
+			for _, check := range checks {
+				if !check.limit.enabled() {
+					continue
+				}
+				allowed, retryAfter, err := rateLimitStore.Take(ctx, check.key, check.limit, now)
+				if err != nil {
+					// Sınırlayıcı arızası kimlik doğrulamayı tamamen durdurmamalı
+					log.Printf("Rate limit hatası (%s): %v", check.key, err)
+					continue
+				}
+				if !allowed {
+					writeRetryAfter(w, retryAfter)
+					http.Error(w, "Çok fazla istek, lütfen daha sonra tekrar deneyin", http.StatusTooManyRequests)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Roller
+const (
+	RoleUser      = "user"
+	RoleOrganizer = "organizer"
+	RoleAdmin     = "admin"
+)
+
+// İzinler "<kaynak>:<işlem>" biçimindedir.
+const (
+	PermEventsRead    = "events:read"
+	PermEventsWrite   = "events:write"
+	PermEventsPublish = "events:publish"
+	PermUsersRead     = "users:read"
+	PermUsersWrite    = "users:write"
+	PermAuditRead     = "audit:read"
+)
+
+// rolePermissions, her rolün verdiği izinlerdir. Rolü olmayan kullanıcılar RoleUser sayılır.
+var rolePermissions = map[string][]string{
+	RoleUser:      {PermEventsRead},
+	RoleOrganizer: {PermEventsRead, PermEventsWrite, PermEventsPublish},
+	RoleAdmin:     {PermEventsRead, PermEventsWrite, PermEventsPublish, PermUsersRead, PermUsersWrite, PermAuditRead},
+}
+
+// validPermission, iznin bir rolde tanımlı olup olmadığını veya tanımlı bir kaynağın
+// "<kaynak>:*" biçimi olup olmadığını kontrol eder.
+func validPermission(perm string) bool {
+	for _, perms := range rolePermissions {
+		for _, known := range perms {
+			if known == perm || strings.SplitN(known, ":", 2)[0]+":*" == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// normalizeList, listedeki boşlukları kırpar, boş ve tekrar eden değerleri atar ve sıralar.
+func normalizeList(values []string) []string {
+	set := map[string]bool{}
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			set[v] = true
+		}
+	}
+	out := make([]string, 0, len(set))
+	for v := range set {
+		out = append(out, v)
+	}
+	sort.Strings(out)
+	return out
+}
+
+type contextKey string
+
+const claimsContextKey contextKey = "claims"
+
+// userRoles, kullanıcının rollerini döndürür; rol atanmamışsa RoleUser varsayılır.
+func userRoles(user *User) []string {
+	if len(user.Roles) == 0 {
+		return []string{RoleUser}
+	}
+	return user.Roles
+}
+
+// effectivePermissions, rollerden gelen izinlerle kullanıcıya doğrudan verilen izinlerin
+// sıralı birleşimidir.
+func effectivePermissions(user *User) []string {
+	set := map[string]bool{}
+	for _, role := range userRoles(user) {
+		for _, perm := range rolePermissions[role] {
+			set[perm] = true
+		}
+	}
+	for _, perm := range user.Permissions {
+		set[perm] = true
+	}
+	perms := make([]string, 0, len(set))
+	for perm := range set {
+		perms = append(perms, perm)
+	}
+	sort.Strings(perms)
+	return perms
+}
+
+// HasPermission, token'ın verilen izni taşıyıp taşımadığını kontrol eder. "events:*" gibi
+// kaynak genelindeki izinler de kabul edilir.
+func (c *Claims) HasPermission(perm string) bool {
+	resource := perm
+	if i := strings.IndexByte(perm, ':'); i >= 0 {
+		resource = perm[:i]
+	}
+	for _, p := range c.Permissions {
+		if p == perm || p == resource+":*" {
+			return true
+		}
+	}
+	return false
+}
+
+// requirePermission, isteği access token ile doğrular ve token'da verilen izin yoksa 403
+// döndürür. Doğrulanan claims handler'a istek context'i ile aktarılır (claimsFromRequest).
+// İzinler token'a yazıldığı için rol değişiklikleri bir sonraki token yenilemede geçerli olur.
+func (s *Server) requirePermission(perm string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			claims, ok := s.authenticateRequest(w, r)
+			if !ok {
+				return
+			}
+			if !claims.HasPermission(perm) {
+				http.Error(w, `{"error": "Bu işlem için yetkiniz yok"}`, http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsContextKey, claims)))
+		})
+	}
+}
+
+// claimsFromRequest, s.requirePermission tarafından doğrulanmış claims'i döndürür.
+func claimsFromRequest(r *http.Request) *Claims {
+	claims, _ := r.Context().Value(claimsContextKey).(*Claims)
+	return claims
+}
+
+// BootstrapAdmins, ADMIN_EMAILS (virgülle ayrılmış) içindeki hesaplara admin rolü verir.
+// İlk yöneticiyi atamanın tek yolu budur; sonrakiler PUT /admin/users/{id}/roles ile atanabilir.
+func (s *Server) BootstrapAdmins() {
+	value := os.Getenv("ADMIN_EMAILS")
+	if value == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, email := range strings.Split(value, ",") {
+		email = normalizeEmail(email)
+		if email == "" {
+			continue
+		}
+		err := s.users.AddRoleByEmail(ctx, email, RoleAdmin)
+		if err == errNotFound {
+			log.Printf("ADMIN_EMAILS içindeki hesap bulunamadı: %s", email)
+		} else if err != nil {
+			log.Printf("Admin rolü atanamadı (%s): %v", email, err)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// revokeToken, verilen token ID'sini (jti) süresi dolana kadar iptal listesine ekler.
+func (s *Server) revokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	if jti == "" {
+		return nil
+	}
+	return s.revocations.Revoke(ctx, jti, expiresAt, time.Now())
+}
+
+// isTokenRevoked, token ID'sinin iptal listesinde olup olmadığını kontrol eder.
+func (s *Server) isTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	return s.revocations.IsRevoked(ctx, jti)
+}
+
+// revokeAllUserTokens, kullanıcının tüm oturumlarını kapatır: refresh token'ları iptal eder
+// ve hâlâ geçerli olabilecek access token'ların ID'lerini iptal listesine ekler.
+func (s *Server) revokeAllUserTokens(ctx context.Context, userID primitive.ObjectID) error {
+	return s.revokeOtherUserTokens(ctx, userID, "")
+}
+
+// revokeOtherUserTokens, keepFamilyID ailesi dışındaki tüm oturumları kapatır. Boş
+// keepFamilyID tüm oturumlar demektir.
+func (s *Server) revokeOtherUserTokens(ctx context.Context, userID primitive.ObjectID, keepFamilyID string) error {
+	// Access token'lar accessTokenTTL kadar yaşar; daha eski kayıtların token'ları zaten geçersiz
+	since := time.Now().Add(-accessTokenTTL)
+	sessions, err := s.sessions.ListCreatedSince(ctx, userID, since, keepFamilyID)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if err := s.revokeToken(ctx, session.AccessJTI, session.CreatedAt.Add(accessTokenTTL)); err != nil {
+			return err
+		}
+	}
+	return s.revokeUserSessions(ctx, userID, keepFamilyID)
+}
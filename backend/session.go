@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute    // Access token kısa ömürlü
+	refreshTokenTTL = 30 * 24 * time.Hour // Refresh token 30 gün geçerli
+)
+
+var (
+	errRefreshTokenInvalid = errors.New("refresh token geçersiz veya süresi dolmuş")
+	errRefreshTokenReused  = errors.New("refresh token yeniden kullanıldı")
+)
+
+// TokenPair, istemciye verilen access ve refresh token ikilisidir.
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64
+}
+
+// generateOpaqueToken, URL güvenli rastgele bir token üretir.
+func generateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken, veritabanında yalnızca token'ın özetini saklamak için kullanılır.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// clientIP, isteğin geldiği IP adresini döndürür. Render proxy'si gerçek adresi
+// X-Forwarded-For listesinin sonuna eklediği için istemcinin yazabileceği baştaki değerler kullanılmaz.
+func clientIP(r *http.Request) string {
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+		parts := strings.Split(fwd, ",")
+		return strings.TrimSpace(parts[len(parts)-1])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// issueTokenPair, kullanıcı için yeni bir token ailesi başlatır ve ilk token ikilisini üretir.
+func (s *Server) issueTokenPair(ctx context.Context, user *User, r *http.Request) (*TokenPair, error) {
+	familyID, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	return s.issueInFamily(ctx, user, familyID, r)
+}
+
+// issueInFamily, verilen aileye yeni bir refresh token ekler ve buna bağlı access token üretir.
+func (s *Server) issueInFamily(ctx context.Context, user *User, familyID string, r *http.Request) (*TokenPair, error) {
+	if err := checkUserActive(user); err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, jti, err := createToken(user, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := Session{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(refreshToken),
+		AccessJTI: jti,
+		ExpiresAt: now.Add(refreshTokenTTL),
+		CreatedAt: now,
+		UserAgent: r.UserAgent(),
+		IP:        clientIP(r),
+	}
+	if err := s.sessions.Create(ctx, &session); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(accessTokenTTL.Seconds()),
+	}, nil
+}
+
+// rotateRefreshToken, refresh token'ı tek kullanımlık olarak tüketir ve aynı ailede yenisini üretir.
+// Daha önce kullanılmış bir token tekrar gelirse tüm aile iptal edilir.
+func (s *Server) rotateRefreshToken(ctx context.Context, refreshToken string, r *http.Request) (*TokenPair, error) {
+	tokenHash := hashToken(refreshToken)
+	now := time.Now()
+
+	session, err := s.sessions.Rotate(ctx, tokenHash, now)
+	if err == errNotFound {
+		// Token ya hiç yok, ya süresi dolmuş ya da daha önce kullanılmış
+		used, err := s.sessions.FindByTokenHash(ctx, tokenHash)
+		if err != nil {
+			s.recordLoginEvent(r, nil, "", "refresh", false, "refresh_token_invalid")
+			return nil, errRefreshTokenInvalid
+		}
+		if used.RotatedAt != nil || used.RevokedAt != nil {
+			if err := s.revokeFamily(ctx, used.FamilyID); err != nil {
+				log.Printf("Token ailesi iptal hatası: %v", err)
+			}
+			s.recordLoginEvent(r, &User{ID: used.UserID}, "", "refresh", false, "refresh_token_reused")
+			return nil, errRefreshTokenReused
+		}
+		s.recordLoginEvent(r, &User{ID: used.UserID}, "", "refresh", false, "refresh_token_expired")
+		return nil, errRefreshTokenInvalid
+	} else if err != nil {
+		return nil, err
+	}
+
+	user, err := s.users.FindByID(ctx, session.UserID)
+	if err != nil {
+		return nil, errRefreshTokenInvalid
+	}
+	if err := checkUserActive(user); err != nil {
+		s.recordLoginEvent(r, user, user.Email, "refresh", false, inactiveReason(err))
+		return nil, errRefreshTokenInvalid
+	}
+
+	pair, err := s.issueInFamily(ctx, user, session.FamilyID, r)
+	if err != nil {
+		return nil, err
+	}
+	s.recordLoginEvent(r, user, user.Email, "refresh", true, "")
+	return pair, nil
+}
+
+// revokeFamily, bir token ailesindeki tüm refresh token'ları iptal eder.
+func (s *Server) revokeFamily(ctx context.Context, familyID string) error {
+	return s.sessions.RevokeFamily(ctx, familyID, time.Now())
+}
+
+// revokeUserSessions, kullanıcının aktif refresh token'larını iptal eder. keepFamilyID
+// boş değilse o aile (isteği yapan cihazın oturumu) açık bırakılır.
+func (s *Server) revokeUserSessions(ctx context.Context, userID primitive.ObjectID, keepFamilyID string) error {
+	return s.sessions.RevokeUser(ctx, userID, keepFamilyID, time.Now())
+}
+
+// refreshTokenHandler, refresh token'ı döndürerek yeni bir token ikilisi verir.
+func (s *Server) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Yalnızca POST destekleniyor", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		http.Error(w, "Geçersiz istek gövdesi", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pair, err := s.rotateRefreshToken(ctx, req.RefreshToken, r)
+	if err == errRefreshTokenInvalid || err == errRefreshTokenReused {
+		http.Error(w, "Geçersiz veya süresi dolmuş refresh token", http.StatusUnauthorized)
+		return
+	} else if err != nil {
+		log.Printf("Token yenileme hatası: %v", err)
+		http.Error(w, "Sunucu hatası", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(TokenResponse{
+		Status:       "success",
+		Message:      "Token yenilendi",
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
+	})
+}
+
+// logoutHandler, mevcut access token'ı ve bağlı olduğu refresh token ailesini iptal eder.
+func (s *Server) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims, ok := s.authenticateRequest(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := s.revokeToken(ctx, claims.Id, time.Unix(claims.ExpiresAt, 0)); err != nil {
+		log.Printf("Token iptal hatası: %v", err)
+		http.Error(w, `{"error": "Çıkış yapılamadı"}`, http.StatusInternalServerError)
+		return
+	}
+	if claims.SessionID != "" {
+		if err := s.revokeFamily(ctx, claims.SessionID); err != nil {
+			log.Printf("Token ailesi iptal hatası: %v", err)
+			http.Error(w, `{"error": "Çıkış yapılamadı"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MessageResponse{Message: "Çıkış yapıldı"})
+}
+
+// logoutAllHandler, kullanıcının tüm cihazlardaki oturumlarını kapatır.
+func (s *Server) logoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims, ok := s.authenticateRequest(w, r)
+	if !ok {
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(claims.Subject)
+	if err != nil {
+		http.Error(w, `{"error": "Geçersiz token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := s.revokeAllUserTokens(ctx, userID); err != nil {
+		log.Printf("Oturum iptal hatası: %v", err)
+		http.Error(w, `{"error": "Oturumlar kapatılamadı"}`, http.StatusInternalServerError)
+		return
+	}
+	// Bu isteği yapan token da listeye eklenir (oturum kaydı eski olsa bile)
+	if err := s.revokeToken(ctx, claims.Id, time.Unix(claims.ExpiresAt, 0)); err != nil {
+		log.Printf("Token iptal hatası: %v", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MessageResponse{Message: "Tüm cihazlardan çıkış yapıldı"})
+}
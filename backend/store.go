@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// errNotFound, aranan kayıt yoksa store'ların döndürdüğü hatadır.
+	errNotFound = errors.New("kayıt bulunamadı")
+	// errConflict, koşullu bir güncelleme kayıt bu sırada değiştiği için uygulanamadığında döner.
+	errConflict = errors.New("kayıt bu sırada değişti")
+)
+
+// normalizeEmail, e-posta adresini saklandığı biçime (boşluksuz, küçük harf) getirir. Kayıtlar
+// bu biçimde yazıldığı için e-postayla yapılan her yazma ve aramadan önce çağrılmalıdır.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// UserFilter, yönetici aramasındaki filtrelerdir. Boş alanlar filtrelenmez.
+type UserFilter struct {
+	Query    string // E-posta, ad veya soyad içinde geçen metin (büyük/küçük harf duyarsız)
+	Role     string
+	Provider string
+	Status   string // "active", "banned" veya "deleted"
+}
+
+// ProfileUpdate, profil güncellemesidir. Boş alanlar değiştirilmez.
+type ProfileUpdate struct {
+	Ad          string
+	Soyad       string
+	Telefon     string
+	DogumTarihi string
+}
+
+// UserStore, kullanıcı kayıtlarının saklandığı yerdir. Koşullu işlemler (ör. ChangePassword,
+// EnableTOTP) eşzamanlı isteklerde yalnızca bir kez başarılı olacak şekilde atomik
+// uygulanmalıdır. Bulunamayan kayıtlar için errNotFound döner.
+type UserStore interface {
+	FindByID(ctx context.Context, id primitive.ObjectID) (*User, error)
+	FindByEmail(ctx context.Context, email string) (*User, error)
+	FindByIdentity(ctx context.Context, provider, subject string) (*User, error)
+	EmailExists(ctx context.Context, email string) (bool, error)
+	// Search, filtreye uyan kullanıcıları yeniden eskiye sıralı olarak ve toplam sayıyla döndürür.
+	Search(ctx context.Context, filter UserFilter, page, limit int) ([]User, int64, error)
+	// ListPurgeable, kalıcı silme zamanı gelmiş hesapları döndürür.
+	ListPurgeable(ctx context.Context, now time.Time) ([]User, error)
+
+	// Create, kullanıcıyı kaydeder ve user.ID'yi doldurur. E-posta kullanılıyorsa errEmailTaken döner.
+	Create(ctx context.Context, user *User) error
+	UpdateProfile(ctx context.Context, id primitive.ObjectID, update ProfileUpdate) error
+	// SetPassword, şifreyi koşulsuz değiştirir ve zorunlu şifre sıfırlama işaretini kaldırır.
+	SetPassword(ctx context.Context, id primitive.ObjectID, hash string) error
+	// ChangePassword, şifreyi yalnızca mevcut özet hâlâ oldHash ise değiştirir; değilse errConflict.
+	// SetPassword gibi zorunlu şifre sıfırlama işaretini de kaldırır.
+	ChangePassword(ctx context.Context, id primitive.ObjectID, oldHash, newHash string) error
+	// ChangeEmail, adresi yalnızca hâlâ oldEmail ise değiştirir. Yeni adres kullanılıyorsa errEmailTaken.
+	ChangeEmail(ctx context.Context, id primitive.ObjectID, oldEmail, newEmail string) error
+
+	// AddIdentity, aynı sağlayıcıdan bağlı kimlik yoksa ekler; varsa errProviderAlreadyLinked.
+	AddIdentity(ctx context.Context, id primitive.ObjectID, identity Identity) error
+	// RemoveIdentity, kimliği kaldırır. keepOne doğruysa son kimlik kaldırılamaz (errLastLoginMethod).
+	RemoveIdentity(ctx context.Context, id primitive.ObjectID, provider string, keepOne bool) error
+
+	SetPendingTOTP(ctx context.Context, id primitive.ObjectID, secret string) error
+	// EnableTOTP, bekleyen anahtar hâlâ pendingSecret ise 2FA'yı açar; değilse errConflict.
+	EnableTOTP(ctx context.Context, id primitive.ObjectID, pendingSecret string, lastStep int64, recoveryHashes []string) error
+	DisableTOTP(ctx context.Context, id primitive.ObjectID) error
+	// AdvanceTOTPStep, son kullanılan adımı yalnızca ileri alır; kod daha önce kullanıldıysa false.
+	AdvanceTOTPStep(ctx context.Context, id primitive.ObjectID, step int64) (bool, error)
+	// UseRecoveryCode, kurtarma kodunu tüketir; kod yoksa veya kullanılmışsa false.
+	UseRecoveryCode(ctx context.Context, id primitive.ObjectID, hash string) (bool, error)
+
+	// MarkDeleted, hesabı kapatır ve kalıcı silmeyi zamanlar; zaten kapatılmışsa bir şey yapmaz.
+	MarkDeleted(ctx context.Context, id primitive.ObjectID, deletedAt, purgeAt time.Time) error
+	// Purge, silme zamanı gelmişse kullanıcıyı kalıcı olarak siler.
+	Purge(ctx context.Context, id primitive.ObjectID, now time.Time) error
+	Ban(ctx context.Context, id primitive.ObjectID, reason string, at time.Time) error
+	Unban(ctx context.Context, id primitive.ObjectID) error
+	RequirePasswordReset(ctx context.Context, id primitive.ObjectID) error
+	// AddRoleByEmail, e-postaya sahip kullanıcıya rol ekler; kullanıcı yoksa errNotFound.
+	AddRoleByEmail(ctx context.Context, email, role string) error
+	// SetRoles, kullanıcının rollerini ve doğrudan verilen izinlerini değiştirir.
+	SetRoles(ctx context.Context, id primitive.ObjectID, roles, permissions []string) error
+}
+
+// VerificationStore, e-postayla gönderilen doğrulama kodlarının özetlerini saklar. Her
+// e-posta ve amaç için en fazla bir geçerli kod bulunur.
+type VerificationStore interface {
+	// Issue, kodu kaydeder ve aynı e-posta ve amaçla önceki kodu geçersiz kılar. Önceki kod
+	// cooldown süresinden daha yeni gönderildiyse *CodeCooldownError döner.
+	Issue(ctx context.Context, code VerificationCode, cooldown time.Duration) error
+	// Consume, kod özeti eşleşirse kaydı tek işlemde siler. Eşleşmezse deneme sayısını artırır;
+	// errCodeInvalid veya maxAttempts'e ulaşıldığında kaydı silip errCodeTooManyAttempts döner.
+	Consume(ctx context.Context, purpose CodePurpose, email, codeHash string, maxAttempts int, now time.Time) error
+	// ListByEmail, e-postaya ait tüm amaçlardaki kodları döndürür (veri dışa aktarma).
+	ListByEmail(ctx context.Context, email string) ([]VerificationCode, error)
+	// DeleteByEmail, e-postaya ait tüm kodları siler (hesabın kalıcı silinmesi).
+	DeleteByEmail(ctx context.Context, email string) error
+}
+
+// SessionStore, refresh token oturumlarını saklar. Aynı girişten türeyen oturumlar bir
+// aile (FamilyID) oluşturur; iptal aile veya kullanıcı bazında yapılır.
+type SessionStore interface {
+	Create(ctx context.Context, session *Session) error
+	// Rotate, özeti tokenHash olan, kullanılmamış, iptal edilmemiş ve süresi dolmamış oturumu
+	// tek işlemde kullanıldı olarak işaretler ve döndürür. Böyle bir oturum yoksa errNotFound;
+	// eşzamanlı iki istekten yalnızca biri aynı oturumu döndürebilir.
+	Rotate(ctx context.Context, tokenHash string, now time.Time) (*Session, error)
+	FindByTokenHash(ctx context.Context, tokenHash string) (*Session, error)
+	// ListActive, kullanıcının kullanılmamış, iptal edilmemiş ve süresi dolmamış oturumlarını
+	// yeniden eskiye döndürür.
+	ListActive(ctx context.Context, userID primitive.ObjectID, now time.Time) ([]Session, error)
+	// ListCreatedSince, since'ten sonra oluşturulmuş, keepFamilyID ailesi dışındaki oturumları döndürür.
+	ListCreatedSince(ctx context.Context, userID primitive.ObjectID, since time.Time, keepFamilyID string) ([]Session, error)
+	RevokeFamily(ctx context.Context, familyID string, now time.Time) error
+	// RevokeUser, kullanıcının keepFamilyID dışındaki tüm oturumlarını iptal eder.
+	RevokeUser(ctx context.Context, userID primitive.ObjectID, keepFamilyID string, now time.Time) error
+}
+
+// RevocationStore, süresi dolmadan iptal edilmiş access token ID'lerini (jti) saklar.
+type RevocationStore interface {
+	// Revoke, jti'yi expiresAt'e kadar iptal listesine ekler; zaten listedeyse bir şey yapmaz.
+	Revoke(ctx context.Context, jti string, expiresAt, now time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// LockoutStore, hesap ve IP anahtarları için hatalı giriş sayaçlarını saklar. Reserve ve
+// Refund eşzamanlı isteklerde her denemenin ayrı sayılacağı şekilde atomik uygulanmalıdır.
+type LockoutStore interface {
+	// Reserve, anahtar kilitli değilse sayacı artırır, yeni sayıya göre kilit süresini yazar
+	// ve kaydı döndürür. Anahtar kilitliyse hiçbir şey değiştirmez ve kalan süreyi döndürür.
+	Reserve(ctx context.Context, key string, policy lockoutPolicy, now time.Time) (*LoginFailure, time.Duration, error)
+	// Refund, bir denemeyi geri verir; kilit süresi yalnızca kısalabilir.
+	Refund(ctx context.Context, key string, policy lockoutPolicy, now time.Time) error
+	Find(ctx context.Context, key string) (*LoginFailure, error)
+	Clear(ctx context.Context, key string) error
+	SetUnlockToken(ctx context.Context, key, tokenHash string) error
+	// ClearByUnlockToken, kilit açma token'ı eşleşen kaydı siler; kayıt yoksa false.
+	ClearByUnlockToken(ctx context.Context, tokenHash string) (bool, error)
+}
+
+// LoginEventStore, giriş denemelerinin geçmişini saklar.
+type LoginEventStore interface {
+	Insert(ctx context.Context, event *LoginEvent) error
+	// HasSuccess, kullanıcının başarılı bir girişi olup olmadığını döndürür. deviceID boş
+	// değilse yalnızca o cihazdan yapılan girişlere bakılır.
+	HasSuccess(ctx context.Context, userID primitive.ObjectID, deviceID string) (bool, error)
+	// ListByUser, kullanıcının son limit denemesini yeniden eskiye döndürür.
+	ListByUser(ctx context.Context, userID primitive.ObjectID, limit int) ([]LoginEvent, error)
+}
+
+// AuditFilter, denetim kaydı aramasındaki filtrelerdir. Boş alanlar filtrelenmez.
+type AuditFilter struct {
+	ActorID  primitive.ObjectID
+	TargetID primitive.ObjectID
+	Action   string
+}
+
+// AuditStore, yönetici işlemlerinin denetim kayıtlarını saklar.
+type AuditStore interface {
+	Insert(ctx context.Context, entry *AuditEntry) error
+	// List, filtreye uyan kayıtları yeniden eskiye sıralı olarak ve toplam sayıyla döndürür.
+	List(ctx context.Context, filter AuditFilter, page, limit int) ([]AuditEntry, int64, error)
+}
+
+// OAuthStateStore, sosyal giriş akışı sürerken state ve PKCE doğrulayıcısını saklar.
+type OAuthStateStore interface {
+	Create(ctx context.Context, state *OAuthState) error
+	// Consume, sağlayıcıya ait süresi dolmamış kaydı tek seferlik siler ve döndürür; yoksa errNotFound.
+	Consume(ctx context.Context, stateHash, provider string, now time.Time) (*OAuthState, error)
+}
+
+// Stores, Server'ın kullandığı store'ların tamamıdır.
+type Stores struct {
+	Users       UserStore
+	Codes       VerificationStore
+	Sessions    SessionStore
+	Revocations RevocationStore
+	Lockouts    LockoutStore
+	LoginEvents LoginEventStore
+	Audit       AuditStore
+	OAuthStates OAuthStateStore
+}
+
+// Server, handler'ların kullandığı store'ları ve e-posta arka ucunu taşır. Handler'lar bu
+// yapının metotlarıdır; testlerde bellek içi store'lar ve MemoryMailer ile oluşturulabilir.
+type Server struct {
+	users       UserStore
+	codes       VerificationStore
+	sessions    SessionStore
+	revocations RevocationStore
+	lockouts    LockoutStore
+	loginEvents LoginEventStore
+	audit       AuditStore
+	oauthStates OAuthStateStore
+	mailer      Mailer
+}
+
+func NewServer(stores Stores, mailer Mailer) *Server {
+	return &Server{
+		users:       stores.Users,
+		codes:       stores.Codes,
+		sessions:    stores.Sessions,
+		revocations: stores.Revocations,
+		lockouts:    stores.Lockouts,
+		loginEvents: stores.LoginEvents,
+		audit:       stores.Audit,
+		oauthStates: stores.OAuthStates,
+		mailer:      mailer,
+	}
+}
@@ -0,0 +1,820 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryUserStore, kullanıcıları bellekte tutan UserStore'dur. Testler ve veritabanısız yerel
+// denemeler içindir. Tüm işlemler tek bir kilit altında yapıldığı için koşullu güncellemeler
+// Mongo'daki gibi atomiktir; dışarıya her zaman kopya verilir.
+type memoryUserStore struct {
+	mu    sync.RWMutex
+	users map[primitive.ObjectID]*User
+}
+
+func NewMemoryUserStore() UserStore {
+	return &memoryUserStore{users: map[primitive.ObjectID]*User{}}
+}
+
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+func cloneUser(user *User) *User {
+	c := *user
+	c.Identities = append([]Identity(nil), user.Identities...)
+	c.Roles = append([]string(nil), user.Roles...)
+	c.Permissions = append([]string(nil), user.Permissions...)
+	c.RecoveryCodes = append([]string(nil), user.RecoveryCodes...)
+	c.DeletedAt = cloneTime(user.DeletedAt)
+	c.PurgeAt = cloneTime(user.PurgeAt)
+	c.BannedAt = cloneTime(user.BannedAt)
+	return &c
+}
+
+// find, koşula uyan ilk kullanıcının kopyasını döndürür. Çağıran kilidi tutmalıdır.
+func (s *memoryUserStore) find(match func(*User) bool) (*User, error) {
+	for _, user := range s.users {
+		if match(user) {
+			return cloneUser(user), nil
+		}
+	}
+	return nil, errNotFound
+}
+
+// update, kullanıcıyı kilit altında değiştirir. apply hata döndürürse değişiklik yapılmamış sayılır.
+func (s *memoryUserStore) update(id primitive.ObjectID, apply func(*User) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return errNotFound
+	}
+	next := cloneUser(user)
+	if err := apply(next); err != nil {
+		return err
+	}
+	s.users[id] = next
+	return nil
+}
+
+func (s *memoryUserStore) emailTaken(email string) bool {
+	for _, user := range s.users {
+		if user.Email == email {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memoryUserStore) FindByID(ctx context.Context, id primitive.ObjectID) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	user, ok := s.users[id]
+	if !ok {
+		return nil, errNotFound
+	}
+	return cloneUser(user), nil
+}
+
+func (s *memoryUserStore) FindByEmail(ctx context.Context, email string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.find(func(u *User) bool { return u.Email == email })
+}
+
+func (s *memoryUserStore) FindByIdentity(ctx context.Context, provider, subject string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.find(func(u *User) bool {
+		for _, identity := range u.Identities {
+			if identity.Provider == provider && identity.Subject == subject {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (s *memoryUserStore) EmailExists(ctx context.Context, email string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.emailTaken(email), nil
+}
+
+func (s *memoryUserStore) Search(ctx context.Context, filter UserFilter, page, limit int) ([]User, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	q := strings.ToLower(strings.TrimSpace(filter.Query))
+	var matched []User
+	for _, user := range s.users {
+		if q != "" && !strings.Contains(strings.ToLower(user.Email), q) &&
+			!strings.Contains(strings.ToLower(user.Ad), q) &&
+			!strings.Contains(strings.ToLower(user.Soyad), q) {
+			continue
+		}
+		if filter.Role != "" {
+			found := false
+			for _, role := range userRoles(user) {
+				found = found || role == filter.Role
+			}
+			if !found {
+				continue
+			}
+		}
+		if filter.Provider != "" && user.Provider != filter.Provider {
+			continue
+		}
+		switch filter.Status {
+		case "active":
+			if user.BannedAt != nil || user.DeletedAt != nil {
+				continue
+			}
+		case "banned":
+			if user.BannedAt == nil {
+				continue
+			}
+		case "deleted":
+			if user.DeletedAt == nil {
+				continue
+			}
+		}
+		matched = append(matched, *cloneUser(user))
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].CreatedAt.After(matched[j].CreatedAt)
+		}
+		return matched[i].ID.Hex() > matched[j].ID.Hex()
+	})
+	total := int64(len(matched))
+	start := (page - 1) * limit
+	if start > len(matched) {
+		start = len(matched)
+	}
+	end := start + limit
+	if end > len(matched) {
+		end = len(matched)
+	}
+	return matched[start:end], total, nil
+}
+
+func (s *memoryUserStore) ListPurgeable(ctx context.Context, now time.Time) ([]User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var users []User
+	for _, user := range s.users {
+		if user.PurgeAt != nil && !user.PurgeAt.After(now) {
+			users = append(users, *cloneUser(user))
+		}
+	}
+	return users, nil
+}
+
+func (s *memoryUserStore) Create(ctx context.Context, user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.emailTaken(user.Email) {
+		return errEmailTaken
+	}
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	s.users[user.ID] = cloneUser(user)
+	return nil
+}
+
+func (s *memoryUserStore) UpdateProfile(ctx context.Context, id primitive.ObjectID, update ProfileUpdate) error {
+	return s.update(id, func(u *User) error {
+		if update.Ad != "" {
+			u.Ad = update.Ad
+		}
+		if update.Soyad != "" {
+			u.Soyad = update.Soyad
+		}
+		if update.Telefon != "" {
+			u.Telefon = update.Telefon
+		}
+		if update.DogumTarihi != "" {
+			u.DogumTarihi = update.DogumTarihi
+		}
+		return nil
+	})
+}
+
+func (s *memoryUserStore) SetPassword(ctx context.Context, id primitive.ObjectID, hash string) error {
+	return s.update(id, func(u *User) error {
+		u.Sifre = hash
+		u.PasswordResetRequired = false
+		return nil
+	})
+}
+
+func (s *memoryUserStore) ChangePassword(ctx context.Context, id primitive.ObjectID, oldHash, newHash string) error {
+	err := s.update(id, func(u *User) error {
+		if u.Sifre != oldHash {
+			return errConflict
+		}
+		u.Sifre = newHash
+		u.PasswordResetRequired = false
+		return nil
+	})
+	if err == errNotFound {
+		return errConflict
+	}
+	return err
+}
+
+func (s *memoryUserStore) ChangeEmail(ctx context.Context, id primitive.ObjectID, oldEmail, newEmail string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.emailTaken(newEmail) {
+		return errEmailTaken
+	}
+	user, ok := s.users[id]
+	if !ok || user.Email != oldEmail {
+		return errNotFound
+	}
+	next := cloneUser(user)
+	next.Email = newEmail
+	s.users[id] = next
+	return nil
+}
+
+func (s *memoryUserStore) AddIdentity(ctx context.Context, id primitive.ObjectID, identity Identity) error {
+	err := s.update(id, func(u *User) error {
+		for _, existing := range u.Identities {
+			if existing.Provider == identity.Provider {
+				return errProviderAlreadyLinked
+			}
+		}
+		u.Identities = append(u.Identities, identity)
+		return nil
+	})
+	if err == errNotFound {
+		return errProviderAlreadyLinked
+	}
+	return err
+}
+
+func (s *memoryUserStore) RemoveIdentity(ctx context.Context, id primitive.ObjectID, provider string, keepOne bool) error {
+	err := s.update(id, func(u *User) error {
+		remaining := make([]Identity, 0, len(u.Identities))
+		for _, identity := range u.Identities {
+			if identity.Provider != provider {
+				remaining = append(remaining, identity)
+			}
+		}
+		if len(remaining) == len(u.Identities) || (keepOne && len(remaining) == 0) {
+			return errLastLoginMethod
+		}
+		u.Identities = remaining
+		return nil
+	})
+	if err == errNotFound {
+		return errLastLoginMethod
+	}
+	return err
+}
+
+func (s *memoryUserStore) SetPendingTOTP(ctx context.Context, id primitive.ObjectID, secret string) error {
+	return s.update(id, func(u *User) error {
+		u.TOTPPendingSecret = secret
+		return nil
+	})
+}
+
+func (s *memoryUserStore) EnableTOTP(ctx context.Context, id primitive.ObjectID, pendingSecret string, lastStep int64, recoveryHashes []string) error {
+	err := s.update(id, func(u *User) error {
+		if u.TOTPPendingSecret != pendingSecret {
+			return errConflict
+		}
+		u.TOTPEnabled = true
+		u.TOTPSecret = pendingSecret
+		u.TOTPLastStep = lastStep
+		u.RecoveryCodes = append([]string(nil), recoveryHashes...)
+		u.TOTPPendingSecret = ""
+		return nil
+	})
+	if err == errNotFound {
+		return errConflict
+	}
+	return err
+}
+
+func (s *memoryUserStore) DisableTOTP(ctx context.Context, id primitive.ObjectID) error {
+	return s.update(id, func(u *User) error {
+		u.TOTPEnabled = false
+		u.TOTPSecret = ""
+		u.TOTPLastStep = 0
+		u.RecoveryCodes = nil
+		u.TOTPPendingSecret = ""
+		return nil
+	})
+}
+
+func (s *memoryUserStore) AdvanceTOTPStep(ctx context.Context, id primitive.ObjectID, step int64) (bool, error) {
+	err := s.update(id, func(u *User) error {
+		if u.TOTPLastStep >= step {
+			return errConflict
+		}
+		u.TOTPLastStep = step
+		return nil
+	})
+	if err == errConflict || err == errNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *memoryUserStore) UseRecoveryCode(ctx context.Context, id primitive.ObjectID, hash string) (bool, error) {
+	err := s.update(id, func(u *User) error {
+		for i, code := range u.RecoveryCodes {
+			if code == hash {
+				u.RecoveryCodes = append(u.RecoveryCodes[:i], u.RecoveryCodes[i+1:]...)
+				return nil
+			}
+		}
+		return errConflict
+	})
+	if err == errConflict || err == errNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *memoryUserStore) MarkDeleted(ctx context.Context, id primitive.ObjectID, deletedAt, purgeAt time.Time) error {
+	err := s.update(id, func(u *User) error {
+		if u.DeletedAt == nil {
+			u.DeletedAt = &deletedAt
+			u.PurgeAt = &purgeAt
+		}
+		return nil
+	})
+	if err == errNotFound {
+		return nil
+	}
+	return err
+}
+
+func (s *memoryUserStore) Purge(ctx context.Context, id primitive.ObjectID, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if user, ok := s.users[id]; ok && user.PurgeAt != nil && !user.PurgeAt.After(now) {
+		delete(s.users, id)
+	}
+	return nil
+}
+
+func (s *memoryUserStore) Ban(ctx context.Context, id primitive.ObjectID, reason string, at time.Time) error {
+	return s.update(id, func(u *User) error {
+		u.BannedAt = &at
+		u.BanReason = reason
+		return nil
+	})
+}
+
+func (s *memoryUserStore) Unban(ctx context.Context, id primitive.ObjectID) error {
+	return s.update(id, func(u *User) error {
+		u.BannedAt = nil
+		u.BanReason = ""
+		return nil
+	})
+}
+
+func (s *memoryUserStore) RequirePasswordReset(ctx context.Context, id primitive.ObjectID) error {
+	return s.update(id, func(u *User) error {
+		u.PasswordResetRequired = true
+		return nil
+	})
+}
+
+func (s *memoryUserStore) AddRoleByEmail(ctx context.Context, email, role string) error {
+	s.mu.RLock()
+	user, err := s.find(func(u *User) bool { return u.Email == email })
+	s.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	return s.update(user.ID, func(u *User) error {
+		for _, existing := range u.Roles {
+			if existing == role {
+				return nil
+			}
+		}
+		u.Roles = append(u.Roles, role)
+		return nil
+	})
+}
+
+func (s *memoryUserStore) SetRoles(ctx context.Context, id primitive.ObjectID, roles, permissions []string) error {
+	return s.update(id, func(u *User) error {
+		u.Roles = append([]string(nil), roles...)
+		u.Permissions = append([]string(nil), permissions...)
+		return nil
+	})
+}
+
+type memoryCodeKey struct {
+	purpose CodePurpose
+	email   string
+}
+
+// memoryVerificationStore, doğrulama kodlarını bellekte tutan VerificationStore'dur.
+type memoryVerificationStore struct {
+	mu    sync.Mutex
+	codes map[memoryCodeKey]*VerificationCode
+}
+
+func NewMemoryVerificationStore() VerificationStore {
+	return &memoryVerificationStore{codes: map[memoryCodeKey]*VerificationCode{}}
+}
+
+func (s *memoryVerificationStore) Issue(ctx context.Context, code VerificationCode, cooldown time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := memoryCodeKey{code.Purpose, code.Email}
+	if existing, ok := s.codes[key]; ok {
+		if wait := existing.LastSentAt.Add(cooldown).Sub(code.LastSentAt); wait > 0 {
+			return &CodeCooldownError{RetryAfter: wait}
+		}
+	}
+	code.ID = primitive.NewObjectID()
+	code.Attempts = 0
+	s.codes[key] = &code
+	return nil
+}
+
+func (s *memoryVerificationStore) Consume(ctx context.Context, purpose CodePurpose, email, codeHash string, maxAttempts int, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := memoryCodeKey{purpose, email}
+	stored, ok := s.codes[key]
+	if !ok {
+		return errCodeInvalid
+	}
+	if stored.CodeHash == codeHash && now.Before(stored.ExpiresAt) && stored.Attempts < maxAttempts {
+		delete(s.codes, key)
+		return nil
+	}
+	stored.Attempts++
+	if now.After(stored.ExpiresAt) {
+		return errCodeInvalid
+	}
+	if stored.Attempts >= maxAttempts {
+		delete(s.codes, key)
+		return errCodeTooManyAttempts
+	}
+	return errCodeInvalid
+}
+
+func (s *memoryVerificationStore) ListByEmail(ctx context.Context, email string) ([]VerificationCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var codes []VerificationCode
+	for key, code := range s.codes {
+		if key.email == email {
+			codes = append(codes, *code)
+		}
+	}
+	return codes, nil
+}
+
+func (s *memoryVerificationStore) DeleteByEmail(ctx context.Context, email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.codes {
+		if key.email == email {
+			delete(s.codes, key)
+		}
+	}
+	return nil
+}
+
+// NewMemoryStores, tüm store'ların bellek içi sürümlerini kurar.
+func NewMemoryStores() Stores {
+	return Stores{
+		Users:       NewMemoryUserStore(),
+		Codes:       NewMemoryVerificationStore(),
+		Sessions:    &memorySessionStore{},
+		Revocations: &memoryRevocationStore{revoked: map[string]time.Time{}},
+		Lockouts:    &memoryLockoutStore{records: map[string]*LoginFailure{}},
+		LoginEvents: &memoryLoginEventStore{},
+		Audit:       &memoryAuditStore{},
+		OAuthStates: &memoryOAuthStateStore{},
+	}
+}
+
+func cloneSession(session *Session) Session {
+	c := *session
+	c.RotatedAt = cloneTime(session.RotatedAt)
+	c.RevokedAt = cloneTime(session.RevokedAt)
+	return c
+}
+
+// memorySessionStore, oturumları eklenme sırasıyla bir dilimde tutar.
+type memorySessionStore struct {
+	mu       sync.RWMutex
+	sessions []*Session
+}
+
+func (s *memorySessionStore) Create(ctx context.Context, session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session.ID = primitive.NewObjectID()
+	c := cloneSession(session)
+	s.sessions = append(s.sessions, &c)
+	return nil
+}
+
+func (s *memorySessionStore) Rotate(ctx context.Context, tokenHash string, now time.Time) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, session := range s.sessions {
+		if session.TokenHash == tokenHash && session.RotatedAt == nil && session.RevokedAt == nil && session.ExpiresAt.After(now) {
+			session.RotatedAt = &now
+			c := cloneSession(session)
+			return &c, nil
+		}
+	}
+	return nil, errNotFound
+}
+
+func (s *memorySessionStore) FindByTokenHash(ctx context.Context, tokenHash string) (*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, session := range s.sessions {
+		if session.TokenHash == tokenHash {
+			c := cloneSession(session)
+			return &c, nil
+		}
+	}
+	return nil, errNotFound
+}
+
+func (s *memorySessionStore) ListActive(ctx context.Context, userID primitive.ObjectID, now time.Time) ([]Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sessions := []Session{}
+	for i := len(s.sessions) - 1; i >= 0; i-- {
+		session := s.sessions[i]
+		if session.UserID == userID && session.RotatedAt == nil && session.RevokedAt == nil && session.ExpiresAt.After(now) {
+			sessions = append(sessions, cloneSession(session))
+		}
+	}
+	return sessions, nil
+}
+
+func (s *memorySessionStore) ListCreatedSince(ctx context.Context, userID primitive.ObjectID, since time.Time, keepFamilyID string) ([]Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var sessions []Session
+	for _, session := range s.sessions {
+		if session.UserID == userID && session.CreatedAt.After(since) && (keepFamilyID == "" || session.FamilyID != keepFamilyID) {
+			sessions = append(sessions, cloneSession(session))
+		}
+	}
+	return sessions, nil
+}
+
+// revoke, koşula uyan ve henüz iptal edilmemiş oturumları iptal eder.
+func (s *memorySessionStore) revoke(match func(*Session) bool, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, session := range s.sessions {
+		if session.RevokedAt == nil && match(session) {
+			at := now
+			session.RevokedAt = &at
+		}
+	}
+}
+
+func (s *memorySessionStore) RevokeFamily(ctx context.Context, familyID string, now time.Time) error {
+	s.revoke(func(session *Session) bool { return session.FamilyID == familyID }, now)
+	return nil
+}
+
+func (s *memorySessionStore) RevokeUser(ctx context.Context, userID primitive.ObjectID, keepFamilyID string, now time.Time) error {
+	s.revoke(func(session *Session) bool {
+		return session.UserID == userID && (keepFamilyID == "" || session.FamilyID != keepFamilyID)
+	}, now)
+	return nil
+}
+
+// memoryRevocationStore, iptal edilen token ID'lerini son geçerlilik zamanlarıyla tutar.
+// Süresi dolan kayıtlar Mongo'daki TTL index'i gibi sayılmaz.
+type memoryRevocationStore struct {
+	mu      sync.RWMutex
+	revoked map[string]time.Time
+}
+
+func (s *memoryRevocationStore) Revoke(ctx context.Context, jti string, expiresAt, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.revoked[jti]; !ok {
+		s.revoked[jti] = expiresAt
+	}
+	return nil
+}
+
+func (s *memoryRevocationStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	expiresAt, ok := s.revoked[jti]
+	return ok && expiresAt.After(time.Now()), nil
+}
+
+// memoryLockoutStore, giriş hatası sayaçlarını anahtara göre tutar.
+type memoryLockoutStore struct {
+	mu      sync.Mutex
+	records map[string]*LoginFailure
+}
+
+func (s *memoryLockoutStore) Reserve(ctx context.Context, key string, policy lockoutPolicy, now time.Time) (*LoginFailure, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.records[key]
+	if !ok {
+		record = &LoginFailure{Key: key}
+		s.records[key] = record
+	}
+	if remaining := record.LockedUntil.Sub(now); remaining > 0 {
+		return nil, remaining, nil
+	}
+	if record.LastFailureAt.Before(now.Add(-policy.Window)) {
+		record.Failures = 1
+	} else {
+		record.Failures++
+	}
+	record.LastFailureAt = now
+	record.LockedUntil = now.Add(policy.delay(record.Failures))
+	c := *record
+	return &c, 0, nil
+}
+
+func (s *memoryLockoutStore) Refund(ctx context.Context, key string, policy lockoutPolicy, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.records[key]
+	if !ok {
+		return nil
+	}
+	if record.Failures > 0 {
+		record.Failures--
+	}
+	if until := now.Add(policy.delay(record.Failures)); until.Before(record.LockedUntil) {
+		record.LockedUntil = until
+	}
+	return nil
+}
+
+func (s *memoryLockoutStore) Find(ctx context.Context, key string) (*LoginFailure, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.records[key]
+	if !ok {
+		return nil, errNotFound
+	}
+	c := *record
+	return &c, nil
+}
+
+func (s *memoryLockoutStore) Clear(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+func (s *memoryLockoutStore) SetUnlockToken(ctx context.Context, key, tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if record, ok := s.records[key]; ok {
+		record.UnlockTokenHash = tokenHash
+	}
+	return nil
+}
+
+func (s *memoryLockoutStore) ClearByUnlockToken(ctx context.Context, tokenHash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, record := range s.records {
+		if record.UnlockTokenHash == tokenHash {
+			delete(s.records, key)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// memoryLoginEventStore, giriş geçmişini eklenme sırasıyla tutar.
+type memoryLoginEventStore struct {
+	mu     sync.RWMutex
+	events []LoginEvent
+}
+
+func (s *memoryLoginEventStore) Insert(ctx context.Context, event *LoginEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	event.ID = primitive.NewObjectID()
+	s.events = append(s.events, *event)
+	return nil
+}
+
+func (s *memoryLoginEventStore) HasSuccess(ctx context.Context, userID primitive.ObjectID, deviceID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, event := range s.events {
+		if event.UserID == userID && event.Success && (deviceID == "" || event.DeviceID == deviceID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *memoryLoginEventStore) ListByUser(ctx context.Context, userID primitive.ObjectID, limit int) ([]LoginEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	events := []LoginEvent{}
+	for i := len(s.events) - 1; i >= 0 && len(events) < limit; i-- {
+		if s.events[i].UserID == userID {
+			events = append(events, s.events[i])
+		}
+	}
+	return events, nil
+}
+
+// memoryAuditStore, denetim kayıtlarını eklenme sırasıyla tutar.
+type memoryAuditStore struct {
+	mu      sync.RWMutex
+	entries []AuditEntry
+}
+
+func (s *memoryAuditStore) Insert(ctx context.Context, entry *AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry.ID = primitive.NewObjectID()
+	s.entries = append(s.entries, *entry)
+	return nil
+}
+
+func (s *memoryAuditStore) List(ctx context.Context, filter AuditFilter, page, limit int) ([]AuditEntry, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var matched []AuditEntry
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		entry := s.entries[i]
+		if (filter.ActorID.IsZero() || entry.ActorID == filter.ActorID) &&
+			(filter.TargetID.IsZero() || entry.TargetID == filter.TargetID) &&
+			(filter.Action == "" || entry.Action == filter.Action) {
+			matched = append(matched, entry)
+		}
+	}
+	entries := []AuditEntry{}
+	if start := (page - 1) * limit; start < len(matched) {
+		end := start + limit
+		if end > len(matched) {
+			end = len(matched)
+		}
+		entries = append(entries, matched[start:end]...)
+	}
+	return entries, int64(len(matched)), nil
+}
+
+// memoryOAuthStateStore, sürmekte olan sosyal giriş akışlarını tutar.
+type memoryOAuthStateStore struct {
+	mu     sync.Mutex
+	states []OAuthState
+}
+
+func (s *memoryOAuthStateStore) Create(ctx context.Context, state *OAuthState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state.ID = primitive.NewObjectID()
+	s.states = append(s.states, *state)
+	return nil
+}
+
+func (s *memoryOAuthStateStore) Consume(ctx context.Context, stateHash, provider string, now time.Time) (*OAuthState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, state := range s.states {
+		if state.StateHash == stateHash && state.Provider == provider && state.ExpiresAt.After(now) {
+			s.states = append(s.states[:i], s.states[i+1:]...)
+			return &state, nil
+		}
+	}
+	return nil, errNotFound
+}
@@ -0,0 +1,745 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoUserStore, kullanıcıları "users" koleksiyonunda tutar.
+type mongoUserStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoUserStore(collection *mongo.Collection) UserStore {
+	return &mongoUserStore{collection: collection}
+}
+
+func (s *mongoUserStore) findOne(ctx context.Context, filter bson.M) (*User, error) {
+	var user User
+	err := s.collection.FindOne(ctx, filter).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, errNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// updateOne, güncellemeyi uygular ve filtreye uyan kayıt yoksa notMatched hatasını döndürür.
+func (s *mongoUserStore) updateOne(ctx context.Context, filter, update bson.M, notMatched error) error {
+	result, err := s.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return notMatched
+	}
+	return nil
+}
+
+func (s *mongoUserStore) FindByID(ctx context.Context, id primitive.ObjectID) (*User, error) {
+	return s.findOne(ctx, bson.M{"_id": id})
+}
+
+func (s *mongoUserStore) FindByEmail(ctx context.Context, email string) (*User, error) {
+	return s.findOne(ctx, bson.M{"email": email})
+}
+
+func (s *mongoUserStore) FindByIdentity(ctx context.Context, provider, subject string) (*User, error) {
+	return s.findOne(ctx, bson.M{"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": subject}}})
+}
+
+func (s *mongoUserStore) EmailExists(ctx context.Context, email string) (bool, error) {
+	count, err := s.collection.CountDocuments(ctx, bson.M{"email": email}, options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+func (s *mongoUserStore) Search(ctx context.Context, filter UserFilter, page, limit int) ([]User, int64, error) {
+	query := bson.M{}
+	var and bson.A
+	if q := strings.TrimSpace(filter.Query); q != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(q), Options: "i"}
+		and = append(and, bson.M{"$or": bson.A{
+			bson.M{"email": pattern},
+			bson.M{"ad": pattern},
+			bson.M{"soyad": pattern},
+		}})
+	}
+	if filter.Role == RoleUser {
+		// Rolü atanmamış kullanıcılar da RoleUser sayılır
+		and = append(and, bson.M{"$or": bson.A{
+			bson.M{"roles": RoleUser},
+			bson.M{"roles": bson.M{"$exists": false}},
+			bson.M{"roles": bson.M{"$size": 0}},
+		}})
+	} else if filter.Role != "" {
+		query["roles"] = filter.Role
+	}
+	if filter.Provider != "" {
+		query["provider"] = filter.Provider
+	}
+	switch filter.Status {
+	case "active":
+		query["bannedAt"] = bson.M{"$exists": false}
+		query["deletedAt"] = bson.M{"$exists": false}
+	case "banned":
+		query["bannedAt"] = bson.M{"$exists": true}
+	case "deleted":
+		query["deletedAt"] = bson.M{"$exists": true}
+	}
+	if len(and) > 0 {
+		query["$and"] = and
+	}
+
+	total, err := s.collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	cursor, err := s.collection.Find(ctx, query, options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64((page-1)*limit)).
+		SetLimit(int64(limit)))
+	if err != nil {
+		return nil, 0, err
+	}
+	var users []User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (s *mongoUserStore) ListPurgeable(ctx context.Context, now time.Time) ([]User, error) {
+	cursor, err := s.collection.Find(ctx, bson.M{"purgeAt": bson.M{"$lte": now}})
+	if err != nil {
+		return nil, err
+	}
+	var users []User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (s *mongoUserStore) Create(ctx context.Context, user *User) error {
+	result, err := s.collection.InsertOne(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		return errEmailTaken
+	} else if err != nil {
+		return err
+	}
+	user.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (s *mongoUserStore) UpdateProfile(ctx context.Context, id primitive.ObjectID, update ProfileUpdate) error {
+	set := bson.M{}
+	if update.Ad != "" {
+		set["ad"] = update.Ad
+	}
+	if update.Soyad != "" {
+		set["soyad"] = update.Soyad
+	}
+	if update.Telefon != "" {
+		set["telefon"] = update.Telefon
+	}
+	if update.DogumTarihi != "" {
+		set["dogumTarihi"] = update.DogumTarihi
+	}
+	if len(set) == 0 {
+		_, err := s.FindByID(ctx, id)
+		return err
+	}
+	return s.updateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set}, errNotFound)
+}
+
+func (s *mongoUserStore) SetPassword(ctx context.Context, id primitive.ObjectID, hash string) error {
+	return s.updateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set":   bson.M{"sifre": hash},
+		"$unset": bson.M{"passwordResetRequired": ""},
+	}, errNotFound)
+}
+
+func (s *mongoUserStore) ChangePassword(ctx context.Context, id primitive.ObjectID, oldHash, newHash string) error {
+	return s.updateOne(ctx,
+		bson.M{"_id": id, "sifre": oldHash},
+		bson.M{
+			"$set":   bson.M{"sifre": newHash},
+			"$unset": bson.M{"passwordResetRequired": ""},
+		},
+		errConflict,
+	)
+}
+
+func (s *mongoUserStore) ChangeEmail(ctx context.Context, id primitive.ObjectID, oldEmail, newEmail string) error {
+	// Unique index bu kontrolü de yapar; index oluşturulmamış kurulumlar için önce sorgulanır
+	taken, err := s.EmailExists(ctx, newEmail)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return errEmailTaken
+	}
+	err = s.updateOne(ctx,
+		bson.M{"_id": id, "email": oldEmail},
+		bson.M{"$set": bson.M{"email": newEmail}},
+		errNotFound,
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return errEmailTaken
+	}
+	return err
+}
+
+func (s *mongoUserStore) AddIdentity(ctx context.Context, id primitive.ObjectID, identity Identity) error {
+	return s.updateOne(ctx,
+		bson.M{"_id": id, "identities.provider": bson.M{"$ne": identity.Provider}},
+		bson.M{"$push": bson.M{"identities": identity}},
+		errProviderAlreadyLinked,
+	)
+}
+
+func (s *mongoUserStore) RemoveIdentity(ctx context.Context, id primitive.ObjectID, provider string, keepOne bool) error {
+	filter := bson.M{"_id": id, "identities.provider": provider}
+	if keepOne {
+		// Kontrol güncellemenin filtresinde yapılır; eşzamanlı iki istek son iki kimliği birden silemez
+		filter["identities.1"] = bson.M{"$exists": true}
+	}
+	return s.updateOne(ctx, filter,
+		bson.M{"$pull": bson.M{"identities": bson.M{"provider": provider}}},
+		errLastLoginMethod,
+	)
+}
+
+func (s *mongoUserStore) SetPendingTOTP(ctx context.Context, id primitive.ObjectID, secret string) error {
+	return s.updateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"totpPendingSecret": secret}}, errNotFound)
+}
+
+func (s *mongoUserStore) EnableTOTP(ctx context.Context, id primitive.ObjectID, pendingSecret string, lastStep int64, recoveryHashes []string) error {
+	return s.updateOne(ctx,
+		bson.M{"_id": id, "totpPendingSecret": pendingSecret},
+		bson.M{
+			"$set": bson.M{
+				"totpEnabled":   true,
+				"totpSecret":    pendingSecret,
+				"totpLastStep":  lastStep,
+				"recoveryCodes": recoveryHashes,
+			},
+			"$unset": bson.M{"totpPendingSecret": ""},
+		},
+		errConflict,
+	)
+}
+
+func (s *mongoUserStore) DisableTOTP(ctx context.Context, id primitive.ObjectID) error {
+	return s.updateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set":   bson.M{"totpEnabled": false},
+		"$unset": bson.M{"totpSecret": "", "totpLastStep": "", "recoveryCodes": "", "totpPendingSecret": ""},
+	}, errNotFound)
+}
+
+func (s *mongoUserStore) AdvanceTOTPStep(ctx context.Context, id primitive.ObjectID, step int64) (bool, error) {
+	result, err := s.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "totpLastStep": bson.M{"$not": bson.M{"$gte": step}}},
+		bson.M{"$set": bson.M{"totpLastStep": step}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+func (s *mongoUserStore) UseRecoveryCode(ctx context.Context, id primitive.ObjectID, hash string) (bool, error) {
+	result, err := s.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "recoveryCodes": hash},
+		bson.M{"$pull": bson.M{"recoveryCodes": hash}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+func (s *mongoUserStore) MarkDeleted(ctx context.Context, id primitive.ObjectID, deletedAt, purgeAt time.Time) error {
+	_, err := s.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "deletedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"deletedAt": deletedAt, "purgeAt": purgeAt}},
+	)
+	return err
+}
+
+func (s *mongoUserStore) Purge(ctx context.Context, id primitive.ObjectID, now time.Time) error {
+	_, err := s.collection.DeleteOne(ctx, bson.M{"_id": id, "purgeAt": bson.M{"$lte": now}})
+	return err
+}
+
+func (s *mongoUserStore) Ban(ctx context.Context, id primitive.ObjectID, reason string, at time.Time) error {
+	return s.updateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"bannedAt": at, "banReason": reason}}, errNotFound)
+}
+
+func (s *mongoUserStore) Unban(ctx context.Context, id primitive.ObjectID) error {
+	return s.updateOne(ctx, bson.M{"_id": id}, bson.M{"$unset": bson.M{"bannedAt": "", "banReason": ""}}, errNotFound)
+}
+
+func (s *mongoUserStore) RequirePasswordReset(ctx context.Context, id primitive.ObjectID) error {
+	return s.updateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"passwordResetRequired": true}}, errNotFound)
+}
+
+func (s *mongoUserStore) AddRoleByEmail(ctx context.Context, email, role string) error {
+	return s.updateOne(ctx, bson.M{"email": email}, bson.M{"$addToSet": bson.M{"roles": role}}, errNotFound)
+}
+
+func (s *mongoUserStore) SetRoles(ctx context.Context, id primitive.ObjectID, roles, permissions []string) error {
+	return s.updateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"roles": roles, "permissions": permissions}}, errNotFound)
+}
+
+// mongoVerificationStore, kodları "verification_codes" koleksiyonunda tutar.
+type mongoVerificationStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoVerificationStore(collection *mongo.Collection) VerificationStore {
+	return &mongoVerificationStore{collection: collection}
+}
+
+// Issue, bekleme süresini güncellemenin filtresinde denetler: önceki kod yeterince eskiyse
+// üzerine yazılır, yoksa eklenir. Bekleme süresindeki bir kayıt filtreye uymadığı için upsert
+// (email, purpose) benzersiz indeksine takılır; bu durum bekleme süresi olarak yorumlanır.
+// Böylece eşzamanlı iki istekten yalnızca biri kod gönderebilir.
+func (s *mongoVerificationStore) Issue(ctx context.Context, code VerificationCode, cooldown time.Duration) error {
+	for {
+		_, err := s.collection.UpdateOne(
+			ctx,
+			bson.M{
+				"email":   code.Email,
+				"purpose": code.Purpose,
+				"$or": bson.A{
+					bson.M{"lastSentAt": bson.M{"$lte": code.LastSentAt.Add(-cooldown)}},
+					bson.M{"lastSentAt": bson.M{"$exists": false}},
+				},
+			},
+			bson.M{
+				"$set": bson.M{
+					"email":      code.Email,
+					"purpose":    code.Purpose,
+					"codeHash":   code.CodeHash,
+					"attempts":   0,
+					"expiresAt":  code.ExpiresAt,
+					"lastSentAt": code.LastSentAt,
+				},
+				"$unset": bson.M{"code": ""}, // Eski sürümden kalan düz metin kodları temizle
+			},
+			options.Update().SetUpsert(true),
+		)
+		if !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+
+		var existing VerificationCode
+		err = s.collection.FindOne(ctx, bson.M{"email": code.Email, "purpose": code.Purpose}).Decode(&existing)
+		if err == mongo.ErrNoDocuments {
+			continue // Kayıt bu arada silindi (kod kullanıldı)
+		} else if err != nil {
+			return err
+		}
+		if wait := existing.LastSentAt.Add(cooldown).Sub(code.LastSentAt); wait > 0 {
+			return &CodeCooldownError{RetryAfter: wait}
+		}
+		// Bekleme bu arada doldu; yeniden dene
+	}
+}
+
+func (s *mongoVerificationStore) Consume(ctx context.Context, purpose CodePurpose, email, codeHash string, maxAttempts int, now time.Time) error {
+	err := s.collection.FindOneAndDelete(ctx, bson.M{
+		"email":     email,
+		"purpose":   purpose,
+		"codeHash":  codeHash,
+		"expiresAt": bson.M{"$gt": now},
+		"attempts":  bson.M{"$lt": maxAttempts},
+	}).Err()
+	if err == nil {
+		return nil
+	} else if err != mongo.ErrNoDocuments {
+		return err
+	}
+
+	var updated VerificationCode
+	err = s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"email": email, "purpose": purpose},
+		bson.M{"$inc": bson.M{"attempts": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return errCodeInvalid
+	} else if err != nil {
+		return err
+	}
+
+	if now.After(updated.ExpiresAt) {
+		return errCodeInvalid
+	}
+	if updated.Attempts >= maxAttempts {
+		if _, err := s.collection.DeleteOne(ctx, bson.M{"_id": updated.ID}); err != nil {
+			return err
+		}
+		return errCodeTooManyAttempts
+	}
+	return errCodeInvalid
+}
+
+func (s *mongoVerificationStore) ListByEmail(ctx context.Context, email string) ([]VerificationCode, error) {
+	cursor, err := s.collection.Find(ctx, bson.M{"email": email})
+	if err != nil {
+		return nil, err
+	}
+	var codes []VerificationCode
+	if err := cursor.All(ctx, &codes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *mongoVerificationStore) DeleteByEmail(ctx context.Context, email string) error {
+	_, err := s.collection.DeleteMany(ctx, bson.M{"email": email})
+	return err
+}
+
+// NewMongoStores, store'ları veritabanındaki koleksiyonlarla kurar.
+func NewMongoStores(db *mongo.Database) Stores {
+	return Stores{
+		Users:       NewMongoUserStore(db.Collection("users")),
+		Codes:       NewMongoVerificationStore(db.Collection("verification_codes")),
+		Sessions:    &mongoSessionStore{collection: db.Collection("sessions")},
+		Revocations: &mongoRevocationStore{collection: db.Collection("revoked_tokens")},
+		Lockouts:    &mongoLockoutStore{collection: db.Collection("login_failures")},
+		LoginEvents: &mongoLoginEventStore{collection: db.Collection("login_events")},
+		Audit:       &mongoAuditStore{collection: db.Collection("audit_log")},
+		OAuthStates: &mongoOAuthStateStore{collection: db.Collection("oauth_states")},
+	}
+}
+
+// findAll, sorgu sonucunu out dilimine okur.
+func findAll(ctx context.Context, coll *mongo.Collection, filter interface{}, opts *options.FindOptions, out interface{}) error {
+	cursor, err := coll.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, out)
+}
+
+// mongoSessionStore, oturumları "sessions" koleksiyonunda tutar.
+type mongoSessionStore struct {
+	collection *mongo.Collection
+}
+
+func (s *mongoSessionStore) Create(ctx context.Context, session *Session) error {
+	result, err := s.collection.InsertOne(ctx, session)
+	if err != nil {
+		return err
+	}
+	session.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (s *mongoSessionStore) Rotate(ctx context.Context, tokenHash string, now time.Time) (*Session, error) {
+	var session Session
+	err := s.collection.FindOneAndUpdate(ctx,
+		bson.M{
+			"tokenHash": tokenHash,
+			"rotatedAt": bson.M{"$exists": false},
+			"revokedAt": bson.M{"$exists": false},
+			"expiresAt": bson.M{"$gt": now},
+		},
+		bson.M{"$set": bson.M{"rotatedAt": now}},
+	).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return nil, errNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (s *mongoSessionStore) FindByTokenHash(ctx context.Context, tokenHash string) (*Session, error) {
+	var session Session
+	err := s.collection.FindOne(ctx, bson.M{"tokenHash": tokenHash}).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return nil, errNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (s *mongoSessionStore) ListActive(ctx context.Context, userID primitive.ObjectID, now time.Time) ([]Session, error) {
+	sessions := []Session{}
+	err := findAll(ctx, s.collection, bson.M{
+		"userId":    userID,
+		"rotatedAt": bson.M{"$exists": false},
+		"revokedAt": bson.M{"$exists": false},
+		"expiresAt": bson.M{"$gt": now},
+	}, options.Find().SetSort(bson.M{"createdAt": -1}), &sessions)
+	return sessions, err
+}
+
+func (s *mongoSessionStore) ListCreatedSince(ctx context.Context, userID primitive.ObjectID, since time.Time, keepFamilyID string) ([]Session, error) {
+	filter := bson.M{"userId": userID, "createdAt": bson.M{"$gt": since}}
+	if keepFamilyID != "" {
+		filter["familyId"] = bson.M{"$ne": keepFamilyID}
+	}
+	var sessions []Session
+	err := findAll(ctx, s.collection, filter, nil, &sessions)
+	return sessions, err
+}
+
+func (s *mongoSessionStore) RevokeFamily(ctx context.Context, familyID string, now time.Time) error {
+	_, err := s.collection.UpdateMany(ctx,
+		bson.M{"familyId": familyID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": now}},
+	)
+	return err
+}
+
+func (s *mongoSessionStore) RevokeUser(ctx context.Context, userID primitive.ObjectID, keepFamilyID string, now time.Time) error {
+	filter := bson.M{"userId": userID, "revokedAt": bson.M{"$exists": false}}
+	if keepFamilyID != "" {
+		filter["familyId"] = bson.M{"$ne": keepFamilyID}
+	}
+	_, err := s.collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revokedAt": now}})
+	return err
+}
+
+// mongoRevocationStore, iptal edilen token ID'lerini "revoked_tokens" koleksiyonunda tutar.
+// Kayıtlar TTL index'iyle token'ın süresi dolunca silinir.
+type mongoRevocationStore struct {
+	collection *mongo.Collection
+}
+
+func (s *mongoRevocationStore) Revoke(ctx context.Context, jti string, expiresAt, now time.Time) error {
+	_, err := s.collection.UpdateOne(ctx,
+		bson.M{"jti": jti},
+		bson.M{"$setOnInsert": bson.M{
+			"jti":       jti,
+			"expiresAt": expiresAt,
+			"revokedAt": now,
+		}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (s *mongoRevocationStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	err := s.collection.FindOne(ctx, bson.M{"jti": jti}).Err()
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// mongoLockoutStore, giriş hatası sayaçlarını "login_failures" koleksiyonunda tutar.
+type mongoLockoutStore struct {
+	collection *mongo.Collection
+}
+
+// Reserve, kilitli olmayan kaydı tek bir pipeline güncellemesiyle artırır. Kilitli kayıt
+// filtreye uymadığı için upsert _id çakışmasına takılır; bu durumda kalan süre okunur.
+func (s *mongoLockoutStore) Reserve(ctx context.Context, key string, policy lockoutPolicy, now time.Time) (*LoginFailure, time.Duration, error) {
+	for {
+		var record LoginFailure
+		err := s.collection.FindOneAndUpdate(ctx,
+			bson.M{"_id": key, "$or": bson.A{
+				bson.M{"lockedUntil": bson.M{"$exists": false}},
+				bson.M{"lockedUntil": bson.M{"$lte": now}},
+			}},
+			mongo.Pipeline{
+				{{Key: "$set", Value: bson.M{
+					"failures": bson.M{"$cond": bson.A{
+						bson.M{"$lt": bson.A{"$lastFailureAt", now.Add(-policy.Window)}},
+						1,
+						bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$failures", 0}}, 1}},
+					}},
+					"lastFailureAt": now,
+				}}},
+				{{Key: "$set", Value: bson.M{"lockedUntil": policy.lockedUntilExpr(now)}}},
+			},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+		).Decode(&record)
+		if err == nil {
+			return &record, 0, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, 0, err
+		}
+
+		// Kayıt var ama filtreye uymadı: anahtar kilitli
+		err = s.collection.FindOne(ctx, bson.M{"_id": key}).Decode(&record)
+		if err == mongo.ErrNoDocuments {
+			continue // Kayıt bu arada silindi (kilit açıldı)
+		} else if err != nil {
+			return nil, 0, err
+		}
+		if remaining := record.LockedUntil.Sub(now); remaining > 0 {
+			return nil, remaining, nil
+		}
+		// Kilit bu arada doldu; yeniden dene
+		now = time.Now()
+	}
+}
+
+func (s *mongoLockoutStore) Refund(ctx context.Context, key string, policy lockoutPolicy, now time.Time) error {
+	_, err := s.collection.UpdateOne(ctx,
+		bson.M{"_id": key},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{
+				"failures": bson.M{"$max": bson.A{bson.M{"$subtract": bson.A{"$failures", 1}}, 0}},
+			}}},
+			{{Key: "$set", Value: bson.M{
+				"lockedUntil": bson.M{"$min": bson.A{"$lockedUntil", policy.lockedUntilExpr(now)}},
+			}}},
+		},
+	)
+	return err
+}
+
+func (s *mongoLockoutStore) Find(ctx context.Context, key string) (*LoginFailure, error) {
+	var record LoginFailure
+	err := s.collection.FindOne(ctx, bson.M{"_id": key}).Decode(&record)
+	if err == mongo.ErrNoDocuments {
+		return nil, errNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (s *mongoLockoutStore) Clear(ctx context.Context, key string) error {
+	_, err := s.collection.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
+
+func (s *mongoLockoutStore) SetUnlockToken(ctx context.Context, key, tokenHash string) error {
+	_, err := s.collection.UpdateOne(ctx, bson.M{"_id": key}, bson.M{"$set": bson.M{"unlockTokenHash": tokenHash}})
+	return err
+}
+
+func (s *mongoLockoutStore) ClearByUnlockToken(ctx context.Context, tokenHash string) (bool, error) {
+	result, err := s.collection.DeleteOne(ctx, bson.M{"unlockTokenHash": tokenHash})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// mongoLoginEventStore, giriş geçmişini "login_events" koleksiyonunda tutar.
+type mongoLoginEventStore struct {
+	collection *mongo.Collection
+}
+
+func (s *mongoLoginEventStore) Insert(ctx context.Context, event *LoginEvent) error {
+	result, err := s.collection.InsertOne(ctx, event)
+	if err != nil {
+		return err
+	}
+	event.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (s *mongoLoginEventStore) HasSuccess(ctx context.Context, userID primitive.ObjectID, deviceID string) (bool, error) {
+	filter := bson.M{"userId": userID, "success": true}
+	if deviceID != "" {
+		filter["deviceId"] = deviceID
+	}
+	count, err := s.collection.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+func (s *mongoLoginEventStore) ListByUser(ctx context.Context, userID primitive.ObjectID, limit int) ([]LoginEvent, error) {
+	events := []LoginEvent{}
+	err := findAll(ctx, s.collection,
+		bson.M{"userId": userID},
+		options.Find().SetSort(bson.M{"createdAt": -1}).SetLimit(int64(limit)),
+		&events,
+	)
+	return events, err
+}
+
+// mongoAuditStore, denetim kayıtlarını "audit_log" koleksiyonunda tutar.
+type mongoAuditStore struct {
+	collection *mongo.Collection
+}
+
+func (s *mongoAuditStore) Insert(ctx context.Context, entry *AuditEntry) error {
+	result, err := s.collection.InsertOne(ctx, entry)
+	if err != nil {
+		return err
+	}
+	entry.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (s *mongoAuditStore) List(ctx context.Context, filter AuditFilter, page, limit int) ([]AuditEntry, int64, error) {
+	query := bson.M{}
+	if !filter.ActorID.IsZero() {
+		query["actorId"] = filter.ActorID
+	}
+	if !filter.TargetID.IsZero() {
+		query["targetId"] = filter.TargetID
+	}
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+
+	total, err := s.collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	entries := []AuditEntry{}
+	err = findAll(ctx, s.collection, query, options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64((page-1)*limit)).
+		SetLimit(int64(limit)), &entries)
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// mongoOAuthStateStore, OAuth akış kayıtlarını "oauth_states" koleksiyonunda tutar.
+type mongoOAuthStateStore struct {
+	collection *mongo.Collection
+}
+
+func (s *mongoOAuthStateStore) Create(ctx context.Context, state *OAuthState) error {
+	result, err := s.collection.InsertOne(ctx, state)
+	if err != nil {
+		return err
+	}
+	state.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (s *mongoOAuthStateStore) Consume(ctx context.Context, stateHash, provider string, now time.Time) (*OAuthState, error) {
+	var stored OAuthState
+	err := s.collection.FindOneAndDelete(ctx, bson.M{
+		"stateHash": stateHash,
+		"provider":  provider,
+		"expiresAt": bson.M{"$gt": now},
+	}).Decode(&stored)
+	if err == mongo.ErrNoDocuments {
+		return nil, errNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// verificationStores, Consume sözleşmesinin denendiği arka uçlardır. Mongo arka ucu yalnızca
+// MONGO_TEST_URI tanımlıysa, test için açılan geçici bir veritabanında denenir.
+func verificationStores(t *testing.T) map[string]func(t *testing.T) VerificationStore {
+	stores := map[string]func(t *testing.T) VerificationStore{
+		"memory": func(t *testing.T) VerificationStore { return NewMemoryVerificationStore() },
+	}
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Log("MONGO_TEST_URI tanımlı değil, Mongo arka ucu atlanıyor")
+		return stores
+	}
+	stores["mongo"] = func(t *testing.T) VerificationStore {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+		if err != nil {
+			t.Fatalf("MongoDB bağlantısı kurulamadı: %v", err)
+		}
+		db := client.Database("etkinlik_test_" + primitive.NewObjectID().Hex())
+		collection := db.Collection("verification_codes")
+		_, err = collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "email", Value: 1}, {Key: "purpose", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+		if err != nil {
+			t.Fatalf("indeks oluşturulamadı: %v", err)
+		}
+		t.Cleanup(func() {
+			db.Drop(context.Background())
+			client.Disconnect(context.Background())
+		})
+		return NewMongoVerificationStore(collection)
+	}
+	return stores
+}
+
+func issueTestCode(t *testing.T, store VerificationStore, email, code string) {
+	t.Helper()
+	now := time.Now()
+	err := store.Issue(context.Background(), VerificationCode{
+		Email:      email,
+		Purpose:    CodePurposeRegister,
+		CodeHash:   hashVerificationCode(CodePurposeRegister, email, code),
+		ExpiresAt:  now.Add(time.Minute),
+		LastSentAt: now,
+	}, codeResendCooldown)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// consumeConcurrently, aynı kodu n eşzamanlı istekle dener ve dönen hataları toplar.
+func consumeConcurrently(store VerificationStore, email, code string, n int) []error {
+	hash := hashVerificationCode(CodePurposeRegister, email, code)
+	errs := make([]error, n)
+	var start, done sync.WaitGroup
+	start.Add(1)
+	for i := 0; i < n; i++ {
+		done.Add(1)
+		go func(i int) {
+			defer done.Done()
+			start.Wait()
+			errs[i] = store.Consume(context.Background(), CodePurposeRegister, email, hash, maxCodeAttempts, time.Now())
+		}(i)
+	}
+	start.Done()
+	done.Wait()
+	return errs
+}
+
+func TestVerificationConsumeOnlyOnce(t *testing.T) {
+	for name, newStore := range verificationStores(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			const email = "ayni@example.com"
+			issueTestCode(t, store, email, "123456")
+
+			successes := 0
+			for _, err := range consumeConcurrently(store, email, "123456", 20) {
+				switch err {
+				case nil:
+					successes++
+				case errCodeInvalid:
+				default:
+					t.Errorf("beklenmeyen hata: %v", err)
+				}
+			}
+			if successes != 1 {
+				t.Errorf("kod %d kez tüketildi, beklenen 1", successes)
+			}
+		})
+	}
+}
+
+func TestVerificationConsumeBurnsAfterMaxAttempts(t *testing.T) {
+	for name, newStore := range verificationStores(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			const email = "deneme@example.com"
+			issueTestCode(t, store, email, "123456")
+
+			tooMany := 0
+			for _, err := range consumeConcurrently(store, email, "654321", 3*maxCodeAttempts) {
+				switch err {
+				case errCodeTooManyAttempts:
+					tooMany++
+				case errCodeInvalid:
+				default:
+					t.Errorf("beklenmeyen hata: %v", err)
+				}
+			}
+			if tooMany == 0 {
+				t.Error("deneme sınırına ulaşıldığı bildirilmedi")
+			}
+
+			// Kod yakıldığı için doğru kod da artık kabul edilmez
+			hash := hashVerificationCode(CodePurposeRegister, email, "123456")
+			if err := store.Consume(context.Background(), CodePurposeRegister, email, hash, maxCodeAttempts, time.Now()); err != errCodeInvalid {
+				t.Errorf("yakılan kod: %v, beklenen errCodeInvalid", err)
+			}
+		})
+	}
+}
+
+func TestVerificationListAndDeleteByEmail(t *testing.T) {
+	for name, newStore := range verificationStores(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			ctx := context.Background()
+			issueTestCode(t, store, "silinecek@example.com", "123456")
+			issueTestCode(t, store, "kalacak@example.com", "123456")
+
+			codes, err := store.ListByEmail(ctx, "silinecek@example.com")
+			if err != nil || len(codes) != 1 {
+				t.Fatalf("ListByEmail: %d kayıt, %v", len(codes), err)
+			}
+			if err := store.DeleteByEmail(ctx, "silinecek@example.com"); err != nil {
+				t.Fatal(err)
+			}
+			if codes, _ := store.ListByEmail(ctx, "silinecek@example.com"); len(codes) != 0 {
+				t.Errorf("silinen e-postada %d kod kaldı", len(codes))
+			}
+			if codes, _ := store.ListByEmail(ctx, "kalacak@example.com"); len(codes) != 1 {
+				t.Errorf("diğer e-postanın kodu silindi")
+			}
+		})
+	}
+}
+
+func TestVerificationIssueCooldown(t *testing.T) {
+	for name, newStore := range verificationStores(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			now := time.Now()
+			code := VerificationCode{Email: "bekle@example.com", Purpose: CodePurposeRegister, ExpiresAt: now.Add(time.Minute), LastSentAt: now}
+
+			// Eşzamanlı isteklerden yalnızca biri kod gönderebilir
+			errs := make([]error, 10)
+			var wg sync.WaitGroup
+			for i := range errs {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					errs[i] = store.Issue(context.Background(), code, codeResendCooldown)
+				}(i)
+			}
+			wg.Wait()
+			issued := 0
+			for _, err := range errs {
+				if err == nil {
+					issued++
+				} else if _, ok := err.(*CodeCooldownError); !ok {
+					t.Errorf("beklenmeyen hata: %v", err)
+				}
+			}
+			if issued != 1 {
+				t.Errorf("%d kod gönderildi, beklenen 1", issued)
+			}
+
+			// Bekleme süresi dolduktan sonra yeni kod gönderilebilir
+			code.LastSentAt = now.Add(codeResendCooldown)
+			if err := store.Issue(context.Background(), code, codeResendCooldown); err != nil {
+				t.Errorf("bekleme sonrası: %v", err)
+			}
+		})
+	}
+}
+
+func TestChangePasswordClearsResetRequired(t *testing.T) {
+	ctx := context.Background()
+	users := NewMemoryUserStore()
+	user := &User{Email: "sifre@example.com", Sifre: "eski-ozet"}
+	if err := users.Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.RequirePasswordReset(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := users.ChangePassword(ctx, user.ID, "baska-ozet", "yeni-ozet"); err != errConflict {
+		t.Errorf("eski özet eşleşmeden: %v, beklenen errConflict", err)
+	}
+	if err := users.ChangePassword(ctx, user.ID, "eski-ozet", "yeni-ozet"); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := users.FindByID(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Sifre != "yeni-ozet" || stored.PasswordResetRequired {
+		t.Errorf("şifre = %q, sıfırlama zorunlu = %v", stored.Sifre, stored.PasswordResetRequired)
+	}
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// RFC 6238 parametreleri; kimlik doğrulayıcı uygulamaların varsayılanlarıyla aynıdır.
+const (
+	totpIssuer    = "Eventra"
+	totpPeriod    = 30
+	totpDigits    = 6
+	totpSkewSteps = 1 // Saat farkı için önceki ve sonraki adım da kabul edilir
+
+	twoFactorChallengeTTL = 5 * time.Minute
+	recoveryCodeCount     = 10
+)
+
+// Hatalı ikinci adım kodları için kullanıcı başına sınır
+var twoFactorAttemptLimit = RateLimit{Burst: 5, Interval: time.Minute}
+
+// generateTOTPSecret, 160 bitlik rastgele bir gizli anahtarı base32 olarak üretir.
+func generateTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b), nil
+}
+
+// totpCode, verilen zaman adımı için RFC 4226 HOTP kodunu hesaplar.
+func totpCode(secret string, step int64) (string, error) {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulus := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		modulus *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%modulus), nil
+}
+
+// verifyTOTP, kodu saat kaymasına izin vererek doğrular ve eşleşen zaman adımını döndürür.
+// lastStep'ten küçük veya eşit adımlar reddedilir; böylece aynı kod iki kez kullanılamaz.
+func verifyTOTP(secret, code string, lastStep int64, now time.Time) (int64, bool) {
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkewSteps; step <= current+totpSkewSteps; step++ {
+		if step <= lastStep {
+			continue
+		}
+		expected, err := totpCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// otpauthURI, kimlik doğrulayıcı uygulamaların okuduğu kayıt adresidir.
+func otpauthURI(email, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", totpIssuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+	label := url.PathEscape(totpIssuer + ":" + email)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// generateRecoveryCodes, tek kullanımlık kurtarma kodlarını ve veritabanına yazılacak özetlerini üretir.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(base32.StdEncoding.EncodeToString(b)) // 8 karakter
+		code := raw[:4] + "-" + raw[4:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	return hashToken(strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", "")))
+}
+
+// checkSecondFactor, TOTP kodunu veya kurtarma kodunu doğrular ve tüketir.
+// Başarılı doğrulamada son kullanılan adım ya da kurtarma kodu atomik olarak güncellenir.
+func (s *Server) checkSecondFactor(ctx context.Context, user *User, code, recoveryCode string) (bool, error) {
+	if recoveryCode != "" {
+		return s.users.UseRecoveryCode(ctx, user.ID, hashRecoveryCode(recoveryCode))
+	}
+
+	step, ok := verifyTOTP(user.TOTPSecret, code, user.TOTPLastStep, time.Now())
+	if !ok {
+		return false, nil
+	}
+	// Aynı kod eşzamanlı iki istekte kullanılamasın diye adım koşullu olarak yazılır
+	return s.users.AdvanceTOTPStep(ctx, user.ID, step)
+}
+
+// allowSecondFactorAttempt, kullanıcı başına ikinci adım denemelerini sınırlar.
+func allowSecondFactorAttempt(ctx context.Context, user *User) (bool, time.Duration) {
+	allowed, retryAfter, err := rateLimitStore.Take(ctx, "2fa:"+user.ID.Hex(), twoFactorAttemptLimit, time.Now())
+	if err != nil {
+		log.Printf("Rate limit hatası (2fa): %v", err)
+		return true, 0
+	}
+	return allowed, retryAfter
+}
+
+// enrollTwoFactorHandler, yeni bir TOTP gizli anahtarı üretir. Anahtar, ilk kod ile
+// onaylanana kadar beklemede kalır ve girişte kullanılmaz.
+func (s *Server) enrollTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims, ok := s.authenticateRequest(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := s.getUserByClaims(claims)
+	if err != nil {
+		http.Error(w, `{"error": "Kullanıcı bulunamadı"}`, http.StatusNotFound)
+		return
+	}
+	if user.Sifre == "" {
+		http.Error(w, `{"error": "İki adımlı doğrulama yalnızca şifreli hesaplarda kullanılabilir"}`, http.StatusBadRequest)
+		return
+	}
+	if user.TOTPEnabled {
+		http.Error(w, `{"error": "İki adımlı doğrulama zaten açık"}`, http.StatusConflict)
+		return
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err = s.users.SetPendingTOTP(ctx, user.ID, secret)
+	if err != nil {
+		log.Printf("TOTP kayıt hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+
+	uri := otpauthURI(user.Email, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		log.Printf("QR kod oluşturma hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(TwoFactorEnrollResponse{
+		Secret:     secret,
+		OtpauthURI: uri,
+		QRCode:     "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	})
+}
+
+// confirmTwoFactorHandler, bekleyen anahtarı ilk kodla doğrular, 2FA'yı açar ve kurtarma kodlarını döndürür.
+func (s *Server) confirmTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims, ok := s.authenticateRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Geçersiz JSON formatı"}`, http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.getUserByClaims(claims)
+	if err != nil {
+		http.Error(w, `{"error": "Kullanıcı bulunamadı"}`, http.StatusNotFound)
+		return
+	}
+	if user.TOTPPendingSecret == "" {
+		http.Error(w, `{"error": "Önce iki adımlı doğrulama kaydı başlatılmalı"}`, http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if allowed, retryAfter := allowSecondFactorAttempt(ctx, user); !allowed {
+		writeRetryAfter(w, retryAfter)
+		http.Error(w, `{"error": "Çok fazla hatalı deneme"}`, http.StatusTooManyRequests)
+		return
+	}
+
+	step, valid := verifyTOTP(user.TOTPPendingSecret, req.Code, 0, time.Now())
+	if !valid {
+		http.Error(w, `{"error": "Kod hatalı"}`, http.StatusUnauthorized)
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+
+	err = s.users.EnableTOTP(ctx, user.ID, user.TOTPPendingSecret, step, hashes)
+	if err != nil {
+		log.Printf("TOTP onay hatası: %v", err)
+		http.Error(w, `{"error": "İki adımlı doğrulama açılamadı"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":       "İki adımlı doğrulama açıldı",
+		"recoveryCodes": codes,
+	})
+}
+
+// disableTwoFactorHandler, şifre ve geçerli bir kodla 2FA'yı kapatır.
+func (s *Server) disableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims, ok := s.authenticateRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Geçersiz JSON formatı"}`, http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.getUserByClaims(claims)
+	if err != nil {
+		http.Error(w, `{"error": "Kullanıcı bulunamadı"}`, http.StatusNotFound)
+		return
+	}
+	if !user.TOTPEnabled {
+		http.Error(w, `{"error": "İki adımlı doğrulama kapalı"}`, http.StatusBadRequest)
+		return
+	}
+	if !checkPasswordHash(req.Sifre, user.Sifre) {
+		http.Error(w, `{"error": "Hatalı şifre"}`, http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if allowed, retryAfter := allowSecondFactorAttempt(ctx, user); !allowed {
+		writeRetryAfter(w, retryAfter)
+		http.Error(w, `{"error": "Çok fazla hatalı deneme"}`, http.StatusTooManyRequests)
+		return
+	}
+
+	valid, err := s.checkSecondFactor(ctx, user, req.Code, req.RecoveryCode)
+	if err != nil {
+		log.Printf("2FA doğrulama hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		http.Error(w, `{"error": "Kod hatalı"}`, http.StatusUnauthorized)
+		return
+	}
+
+	err = s.users.DisableTOTP(ctx, user.ID)
+	if err != nil {
+		log.Printf("2FA kapatma hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MessageResponse{Message: "İki adımlı doğrulama kapatıldı"})
+}
+
+// loginTwoFactorHandler, şifre adımından dönen challenge token'ı ve TOTP/kurtarma kodunu
+// alır; ikisi de geçerliyse gerçek token ikilisini verir.
+func (s *Server) loginTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Yalnızca POST destekleniyor", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req TwoFactorLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ChallengeToken == "" {
+		http.Error(w, "Geçersiz istek gövdesi", http.StatusBadRequest)
+		return
+	}
+
+	challenge, err := s.parsePurposeToken(req.ChallengeToken, "2fa")
+	if err != nil {
+		http.Error(w, "Doğrulama oturumu geçersiz veya süresi dolmuş", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := s.getUserByClaims(challenge)
+	if err != nil || !user.TOTPEnabled {
+		http.Error(w, "Doğrulama oturumu geçersiz veya süresi dolmuş", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if allowed, retryAfter := allowSecondFactorAttempt(ctx, user); !allowed {
+		writeRetryAfter(w, retryAfter)
+		http.Error(w, "Çok fazla hatalı deneme, lütfen daha sonra tekrar deneyin", http.StatusTooManyRequests)
+		return
+	}
+
+	valid, err := s.checkSecondFactor(ctx, user, req.Code, req.RecoveryCode)
+	if err != nil {
+		log.Printf("2FA doğrulama hatası: %v", err)
+		http.Error(w, "Sunucu hatası", http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		s.recordLoginEvent(r, user, user.Email, "2fa", false, "wrong_code")
+		http.Error(w, "Kod hatalı", http.StatusUnauthorized)
+		return
+	}
+
+	// Challenge token tek kullanımlıktır
+	if err := s.revokeToken(ctx, challenge.Id, time.Unix(challenge.ExpiresAt, 0)); err != nil {
+		log.Printf("Token iptal hatası: %v", err)
+	}
+
+	pair, err := s.issueLoginTokens(ctx, user, r, "2fa")
+	if err != nil {
+		log.Printf("Token oluşturma hatası: %v", err)
+		http.Error(w, "Token oluşturulamadı", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(TokenResponse{
+		Status:       "success",
+		Message:      "Giriş başarılı",
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
+	})
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/go-webauthn/webauthn/webauthn"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -19,15 +21,49 @@ type User struct {
 	Sifre       string             `json:"sifre" bson:"sifre,omitempty"`       // Sosyal girişlerde boş kalabilir
 	Provider    string             `json:"provider" bson:"provider"`           // 'email', 'google', 'facebook'
 	SocialID    string             `json:"socialId" bson:"socialId,omitempty"` // Google/Facebook ID'si
+	Identities  []Identity         `json:"identities" bson:"identities,omitempty"`
 	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
+
+	// Yetkilendirme: izinler rollerden gelir, Permissions ile tek tek ek izin verilebilir
+	Roles       []string `json:"roles" bson:"roles,omitempty"`
+	Permissions []string `json:"permissions" bson:"permissions,omitempty"`
+
+	// İki adımlı doğrulama (TOTP). Gizli anahtar ve kurtarma kodları hiçbir yanıtta dönmez.
+	TOTPEnabled       bool     `json:"-" bson:"totpEnabled,omitempty"`
+	TOTPSecret        string   `json:"-" bson:"totpSecret,omitempty"`
+	TOTPPendingSecret string   `json:"-" bson:"totpPendingSecret,omitempty"` // Onaylanana kadar girişte kullanılmaz
+	TOTPLastStep      int64    `json:"-" bson:"totpLastStep,omitempty"`      // Aynı kodun tekrar kullanılmasını engeller
+	RecoveryCodes     []string `json:"-" bson:"recoveryCodes,omitempty"`     // SHA-256 özetleri
+
+	// Hesap silme: DeletedAt dolu hesaplar giriş yapamaz, PurgeAt geldiğinde kalıcı olarak silinir
+	DeletedAt *time.Time `json:"-" bson:"deletedAt,omitempty"`
+	PurgeAt   *time.Time `json:"-" bson:"purgeAt,omitempty"`
+
+	// Yönetici işlemleri: askıya alınan hesaplar hiçbir yolla giriş yapamaz
+	BannedAt              *time.Time `json:"-" bson:"bannedAt,omitempty"`
+	BanReason             string     `json:"-" bson:"banReason,omitempty"`
+	PasswordResetRequired bool       `json:"-" bson:"passwordResetRequired,omitempty"` // Şifre sıfırlanana kadar şifreyle giriş yapılamaz
+}
+
+// Identity, kullanıcıya bağlı bir sosyal giriş hesabıdır. Provider ve Subject birlikte
+// kullanıcıyı sağlayıcı tarafında tekil olarak tanımlar.
+type Identity struct {
+	Provider string    `json:"provider" bson:"provider"`
+	Subject  string    `json:"subject" bson:"subject"`
+	Email    string    `json:"email" bson:"email,omitempty"`
+	LinkedAt time.Time `json:"linkedAt" bson:"linkedAt"`
 }
 
 // VerificationCode, email doğrulama kodlarını geçici olarak saklar.
+// Kodun kendisi değil, yalnızca özeti tutulur.
 type VerificationCode struct {
-	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Email     string             `json:"email" bson:"email"`
-	Code      string             `json:"code" bson:"code"`
-	ExpiresAt time.Time          `json:"expiresAt" bson:"expiresAt"`
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Email      string             `json:"email" bson:"email"`
+	Purpose    CodePurpose        `json:"purpose" bson:"purpose"`
+	CodeHash   string             `json:"-" bson:"codeHash"`
+	Attempts   int                `json:"attempts" bson:"attempts"`
+	ExpiresAt  time.Time          `json:"expiresAt" bson:"expiresAt"`
+	LastSentAt time.Time          `json:"lastSentAt" bson:"lastSentAt"`
 }
 
 type LoginRequest struct {
@@ -56,35 +92,339 @@ type MessageResponse struct {
 
 // GoogleUser, Google'dan gelen kullanıcı bilgilerini tutar
 type GoogleUser struct {
+	ID            string `json:"id"`
 	Email         string `json:"email"`
-	VerifiedEmail bool   `json:"email_verified"`
+	VerifiedEmail bool   `json:"verified_email"` // oauth2/v2/userinfo bu adı kullanır
 	Name          string `json:"name"`
 	GivenName     string `json:"given_name"`
 	FamilyName    string `json:"family_name"`
 	Picture       string `json:"picture"`
 }
 
+// GoogleTokenRequest, native SDK'dan alınan ID token ile giriş isteğidir
+type GoogleTokenRequest struct {
+	IDToken string `json:"idToken"`
+	Nonce   string `json:"nonce"` // İstemci SDK'ya nonce verdiyse aynı değer
+}
+
+// FacebookUser, Graph API "/me" yanıtındaki kullanıcı bilgilerini tutar
+type FacebookUser struct {
+	ID        string `json:"id"`
+	Email     string `json:"email"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
 type ResetPasswordRequest struct {
 	Email       string `json:"email"`
 	Code        string `json:"code"`
 	NewPassword string `json:"newPassword"`
 }
 
+// ChangePasswordRequest, oturum açmış kullanıcının şifre değiştirme isteğidir
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+// EmailChange, bekleyen veya tamamlanmış bir e-posta değişikliğidir. Onaylandıktan sonra
+// kayıt, eski adrese gönderilen geri alma bağlantısı için saklanır.
+type EmailChange struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty"`
+	UserID        primitive.ObjectID `bson:"userId"`
+	OldEmail      string             `bson:"oldEmail"`
+	NewEmail      string             `bson:"newEmail"`
+	ExpiresAt     time.Time          `bson:"expiresAt"`
+	CreatedAt     time.Time          `bson:"createdAt"`
+	ConfirmedAt   *time.Time         `bson:"confirmedAt,omitempty"`
+	UndoTokenHash string             `bson:"undoTokenHash,omitempty"`
+	UndoExpiresAt *time.Time         `bson:"undoExpiresAt,omitempty"`
+	UndoneAt      *time.Time         `bson:"undoneAt,omitempty"`
+}
+
+type EmailChangeRequest struct {
+	Email string `json:"email"` // Yeni adres
+	Sifre string `json:"sifre"` // Şifreli hesaplarda mevcut şifre
+}
+
+type EmailConfirmRequest struct {
+	Code string `json:"code"`
+}
+
+// LoginEvent, başarılı veya başarısız bir giriş denemesidir.
+type LoginEvent struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `json:"-" bson:"userId,omitempty"` // Bilinmeyen e-postalarla yapılan denemelerde boş
+	Email     string             `json:"-" bson:"email"`
+	Method    string             `json:"method" bson:"method"` // "password", "2fa", "google", "passkey", "magic-link", "refresh"...
+	Success   bool               `json:"success" bson:"success"`
+	Reason    string             `json:"reason,omitempty" bson:"reason,omitempty"`
+	IP        string             `json:"ip" bson:"ip"`
+	UserAgent string             `json:"userAgent" bson:"userAgent"`
+	DeviceID  string             `json:"deviceId" bson:"deviceId"`
+	NewDevice bool               `json:"newDevice" bson:"newDevice"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+// LoginFailure, bir hesap ("account:<id>") veya IP ("ip:<adres>") için art arda hatalı
+// giriş sayacı ve kilit durumudur.
+type LoginFailure struct {
+	Key             string    `json:"key" bson:"_id"`
+	Failures        int       `json:"failures" bson:"failures"`
+	LastFailureAt   time.Time `json:"lastFailureAt" bson:"lastFailureAt"`
+	LockedUntil     time.Time `json:"lockedUntil,omitempty" bson:"lockedUntil,omitempty"`
+	UnlockTokenHash string    `json:"-" bson:"unlockTokenHash,omitempty"`
+}
+
+// AuditEntry, bir yöneticinin yaptığı işlemin denetim kaydıdır.
+type AuditEntry struct {
+	ID         primitive.ObjectID     `json:"id" bson:"_id,omitempty"`
+	ActorID    primitive.ObjectID     `json:"actorId" bson:"actorId"`
+	ActorEmail string                 `json:"actorEmail" bson:"actorEmail"`
+	Action     string                 `json:"action" bson:"action"` // "user.ban", "user.view", "lockout.clear"...
+	TargetID   primitive.ObjectID     `json:"targetId,omitempty" bson:"targetId,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty" bson:"details,omitempty"`
+	IP         string                 `json:"ip" bson:"ip"`
+	CreatedAt  time.Time              `json:"createdAt" bson:"createdAt"`
+}
+
+// AdminUserResponse, yönetici API'sinde kullanıcı özetidir; gizli alanlar yer almaz.
+type AdminUserResponse struct {
+	ID                    string     `json:"id"`
+	Ad                    string     `json:"ad"`
+	Soyad                 string     `json:"soyad"`
+	Email                 string     `json:"email"`
+	Telefon               string     `json:"telefon,omitempty"`
+	Provider              string     `json:"provider"`
+	Identities            []Identity `json:"identities"`
+	Roles                 []string   `json:"roles"`
+	HasPassword           bool       `json:"hasPassword"`
+	TwoFactor             bool       `json:"twoFactorEnabled"`
+	PasswordResetRequired bool       `json:"passwordResetRequired"`
+	CreatedAt             time.Time  `json:"createdAt"`
+	BannedAt              *time.Time `json:"bannedAt,omitempty"`
+	BanReason             string     `json:"banReason,omitempty"`
+	DeletedAt             *time.Time `json:"deletedAt,omitempty"`
+	PurgeAt               *time.Time `json:"purgeAt,omitempty"`
+}
+
+// AdminUserListResponse, sayfalı kullanıcı arama sonucudur.
+type AdminUserListResponse struct {
+	Users []AdminUserResponse `json:"users"`
+	Total int64               `json:"total"`
+	Page  int                 `json:"page"`
+	Limit int                 `json:"limit"`
+}
+
+// AdminUserDetailResponse, kullanıcıyı bağlı kayıtlarıyla birlikte döndürür.
+type AdminUserDetailResponse struct {
+	User         AdminUserResponse    `json:"user"`
+	Sessions     []Session            `json:"sessions"`
+	Passkeys     []WebAuthnCredential `json:"passkeys"`
+	RecentLogins []LoginEvent         `json:"recentLogins"`
+	Lockout      *AdminLockoutStatus  `json:"lockout,omitempty"`
+}
+
+// AdminLockoutStatus, hesabın giriş kilidi durumudur. Kilit kaydının anahtarı ve kilit açma
+// bağlantısının özeti yöneticiye gösterilmez.
+type AdminLockoutStatus struct {
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"lastFailureAt"`
+	Locked        bool       `json:"locked"`
+	LockedUntil   *time.Time `json:"lockedUntil,omitempty"`
+}
+
+// BanUserRequest, hesabı askıya alma isteğidir.
+type BanUserRequest struct {
+	Reason string `json:"reason"`
+}
+
+// SetRolesRequest, kullanıcının rollerini ve rollere ek olarak doğrudan verilen izinlerini
+// bütünüyle değiştirir.
+type SetRolesRequest struct {
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+// DeleteAccountRequest, hesap silmeden önce kimliği yeniden doğrulamak için kullanılır
+type DeleteAccountRequest struct {
+	Sifre        string `json:"sifre"`
+	EmailCode    string `json:"emailCode"`    // Şifresiz hesaplarda, POST /user/delete/code ile gönderilen kod
+	Code         string `json:"code"`         // 2FA açıksa
+	RecoveryCode string `json:"recoveryCode"` // 2FA açıksa, kod yerine
+}
+
+// FieldError, istekteki tek bir alana ait doğrulama hatasıdır. Code, istemcinin
+// mesajı kendi dilinde göstermesi için sabit bir anahtardır.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type ValidationErrorResponse struct {
+	Error  string       `json:"error"`
+	Fields []FieldError `json:"fields"`
+}
+
 type Claims struct {
-	Email string `json:"email"`
+	Email       string   `json:"email"`
+	SessionID   string   `json:"sid,omitempty"`     // Token'ın bağlı olduğu refresh token ailesi
+	Purpose     string   `json:"purpose,omitempty"` // Boş değilse access token değil, tek amaçlı token
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"perms,omitempty"`
 	jwt.StandardClaims
 }
 
+// Session, refresh token'ların sunucu tarafındaki kaydıdır. Her döndürmede
+// aynı FamilyID ile yeni bir kayıt oluşturulur, eskisi RotatedAt ile işaretlenir.
+type Session struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `json:"userId" bson:"userId"`
+	FamilyID  string             `json:"familyId" bson:"familyId"`
+	TokenHash string             `json:"-" bson:"tokenHash"` // Token'ın kendisi değil, SHA-256 özeti
+	AccessJTI string             `json:"-" bson:"accessJti"` // Bu kayıtla birlikte verilen access token'ın ID'si
+	ExpiresAt time.Time          `json:"expiresAt" bson:"expiresAt"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	RotatedAt *time.Time         `json:"rotatedAt,omitempty" bson:"rotatedAt,omitempty"`
+	RevokedAt *time.Time         `json:"revokedAt,omitempty" bson:"revokedAt,omitempty"`
+	UserAgent string             `json:"userAgent" bson:"userAgent,omitempty"`
+	IP        string             `json:"ip" bson:"ip,omitempty"`
+}
+
+// RevokedToken, süresi dolmadan iptal edilmiş bir access token'ın ID'sidir.
+type RevokedToken struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	JTI       string             `json:"jti" bson:"jti"`
+	ExpiresAt time.Time          `json:"expiresAt" bson:"expiresAt"`
+	RevokedAt time.Time          `json:"revokedAt" bson:"revokedAt"`
+}
+
+// OAuthState, başlatılmış bir sosyal giriş akışının sunucu tarafı kaydıdır.
+type OAuthState struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty"`
+	StateHash    string             `bson:"stateHash"`
+	Provider     string             `bson:"provider"`
+	CodeVerifier string             `bson:"codeVerifier"` // PKCE doğrulayıcısı
+	Nonce        string             `bson:"nonce,omitempty"`
+	LinkUserID   primitive.ObjectID `bson:"linkUserId,omitempty"` // Doluysa giriş değil, bu kullanıcıya hesap bağlama
+	RedirectURI  string             `bson:"redirectUri"`          // Giriş sonrası dönülecek adres
+	CreatedAt    time.Time          `bson:"createdAt"`
+	ExpiresAt    time.Time          `bson:"expiresAt"`
+}
+
+// WebAuthnCredential, kullanıcıya kayıtlı bir passkey'dir. Credential alanı kütüphanenin
+// doğrulama için ihtiyaç duyduğu açık anahtar ve sayaç bilgilerini taşır.
+type WebAuthnCredential struct {
+	ID           primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	UserID       primitive.ObjectID  `json:"-" bson:"userId"`
+	CredentialID []byte              `json:"-" bson:"credentialId"`
+	Name         string              `json:"name" bson:"name"`
+	Credential   webauthn.Credential `json:"-" bson:"credential"`
+	CreatedAt    time.Time           `json:"createdAt" bson:"createdAt"`
+	LastUsedAt   *time.Time          `json:"lastUsedAt,omitempty" bson:"lastUsedAt,omitempty"`
+}
+
+// WebAuthnSession, başlatılmış bir kayıt veya giriş seremonisinin challenge bilgisidir.
+type WebAuthnSession struct {
+	ID        primitive.ObjectID   `bson:"_id,omitempty"`
+	TokenHash string               `bson:"tokenHash"`
+	Ceremony  string               `bson:"ceremony"` // "register" veya "login"
+	UserID    primitive.ObjectID   `bson:"userId,omitempty"`
+	Data      webauthn.SessionData `bson:"data"`
+	ExpiresAt time.Time            `bson:"expiresAt"`
+}
+
+// WebAuthnFinishRequest, seremoninin ikinci adımında gönderilir. Credential, tarayıcının
+// navigator.credentials çağrısından dönen nesnedir.
+type WebAuthnFinishRequest struct {
+	SessionID  string          `json:"sessionId"`
+	Name       string          `json:"name"` // Yalnızca kayıtta; ör. "iPhone"
+	Credential json.RawMessage `json:"credential"`
+}
+
+// MagicLink, e-postayla gönderilen giriş bağlantısının sunucu tarafı kaydıdır. Bağlantıdaki
+// imzalı token'ın yalnızca özeti saklanır; tek kullanımlık olması UsedAt ile sağlanır.
+type MagicLink struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	TokenHash   string             `bson:"tokenHash"`
+	UserID      primitive.ObjectID `bson:"userId"`
+	RedirectURI string             `bson:"redirectUri"`
+	CreatedAt   time.Time          `bson:"createdAt"`
+	ExpiresAt   time.Time          `bson:"expiresAt"`
+	UsedAt      *time.Time         `bson:"usedAt,omitempty"`
+}
+
+type MagicLinkRequest struct {
+	Email       string `json:"email"`
+	RedirectURI string `json:"redirectUri"` // Bağlantı tarayıcıda açıldığında dönülecek uygulama adresi
+}
+
+type MagicLinkVerifyRequest struct {
+	Token string `json:"token"`
+}
+
+type LoginCodeRequest struct {
+	Email string `json:"email"`
+	Code  string `json:"code"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// TokenResponse, giriş ve token yenileme yanıtlarında kullanılır
+type TokenResponse struct {
+	Status       string `json:"status"`
+	Message      string `json:"message"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"`
+}
+
+// TwoFactorLoginResponse, 2FA açık hesaplarda şifre adımından sonra döner
+type TwoFactorLoginResponse struct {
+	Status         string `json:"status"` // "2fa_required"
+	Message        string `json:"message"`
+	ChallengeToken string `json:"challengeToken"`
+}
+
+// TwoFactorLoginRequest, girişin ikinci adımında gönderilir. Code veya RecoveryCode'dan biri yeterlidir.
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challengeToken"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recoveryCode"`
+}
+
+// TwoFactorCodeRequest, 2FA onaylama ve kapatma isteklerinde kullanılır
+type TwoFactorCodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+	Sifre        string `json:"sifre"` // Yalnızca kapatırken gerekir
+}
+
+// TwoFactorEnrollResponse, kimlik doğrulayıcı uygulamaya eklenecek bilgileri içerir
+type TwoFactorEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauthUri"`
+	QRCode     string `json:"qrCode"` // PNG data URI
+}
+
 // UserProfileResponse, kullanıcı profil bilgilerini döndürmek için kullanılır
 type UserProfileResponse struct {
-	ID          string    `json:"id"`
-	Ad          string    `json:"ad"`
-	Soyad       string    `json:"soyad"`
-	Email       string    `json:"email"`
-	Telefon     string    `json:"telefon,omitempty"`
-	DogumTarihi string    `json:"dogumTarihi,omitempty"`
-	Provider    string    `json:"provider"`
-	CreatedAt   time.Time `json:"createdAt"`
+	ID          string     `json:"id"`
+	Ad          string     `json:"ad"`
+	Soyad       string     `json:"soyad"`
+	Email       string     `json:"email"`
+	Telefon     string     `json:"telefon,omitempty"`
+	DogumTarihi string     `json:"dogumTarihi,omitempty"`
+	Provider    string     `json:"provider"`
+	Identities  []Identity `json:"identities"`
+	HasPassword bool       `json:"hasPassword"`
+	TwoFactor   bool       `json:"twoFactorEnabled"`
+	Roles       []string   `json:"roles"`
+	Permissions []string   `json:"permissions"`
+	CreatedAt   time.Time  `json:"createdAt"`
 }
 
 // UpdateProfileRequest, profil güncelleme isteği için kullanılır
@@ -3,19 +3,19 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
 	"strings"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 
 
 // getUserProfileHandler, kullanıcının profil bilgilerini döndürür
-func getUserProfileHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) getUserProfileHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	// Authorization header'ından token'ı al
@@ -33,16 +33,16 @@ func getUserProfileHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Token'ı doğrula ve email'i çıkar
-	email, err := validateTokenAndGetEmail(tokenString)
+	email, err := s.validateTokenAndGetEmail(tokenString)
 	if err != nil {
 		http.Error(w, `{"error": "Geçersiz token"}`, http.StatusUnauthorized)
 		return
 	}
 
 	// Kullanıcıyı veritabanından bul
-	user, err := getUserByEmail(email)
+	user, err := s.getUserByEmail(email)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == errNotFound {
 			http.Error(w, `{"error": "Kullanıcı bulunamadı"}`, http.StatusNotFound)
 			return
 		}
@@ -59,6 +59,11 @@ func getUserProfileHandler(w http.ResponseWriter, r *http.Request) {
 		Telefon:     user.Telefon,
 		DogumTarihi: user.DogumTarihi,
 		Provider:    user.Provider,
+		Identities:  user.Identities,
+		HasPassword: user.Sifre != "",
+		TwoFactor:   user.TOTPEnabled,
+		Roles:       userRoles(user),
+		Permissions: effectivePermissions(user),
 		CreatedAt:   user.CreatedAt,
 	}
 
@@ -68,7 +73,7 @@ func getUserProfileHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // updateUserProfileHandler, kullanıcının profil bilgilerini günceller
-func updateUserProfileHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) updateUserProfileHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	// Authorization header'ından token'ı al
@@ -86,7 +91,7 @@ func updateUserProfileHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Token'ı doğrula ve email'i çıkar
-	email, err := validateTokenAndGetEmail(tokenString)
+	email, err := s.validateTokenAndGetEmail(tokenString)
 	if err != nil {
 		http.Error(w, `{"error": "Geçersiz token"}`, http.StatusUnauthorized)
 		return
@@ -99,41 +104,36 @@ func updateUserProfileHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Güncelleme verilerini hazırla
-	updateData := bson.M{}
-	if updateReq.Ad != "" {
-		updateData["ad"] = updateReq.Ad
-	}
-	if updateReq.Soyad != "" {
-		updateData["soyad"] = updateReq.Soyad
-	}
-	if updateReq.Telefon != "" {
-		updateData["telefon"] = updateReq.Telefon
-	}
-	if updateReq.DogumTarihi != "" {
-		updateData["dogumTarihi"] = updateReq.DogumTarihi
-	}
-
 	// Veritabanında güncelle
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	filter := bson.M{"email": email}
-	update := bson.M{"$set": updateData}
-
-	result, err := usersCollection.UpdateOne(ctx, filter, update)
-	if err != nil {
+	user, err := s.users.FindByEmail(ctx, email)
+	if err == errNotFound {
+		http.Error(w, `{"error": "Kullanıcı bulunamadı"}`, http.StatusNotFound)
+		return
+	} else if err != nil {
 		http.Error(w, `{"error": "Profil güncellenirken hata oluştu"}`, http.StatusInternalServerError)
 		return
 	}
 
-	if result.MatchedCount == 0 {
+	// Boş bırakılan alanlar değiştirilmez
+	err = s.users.UpdateProfile(ctx, user.ID, ProfileUpdate{
+		Ad:          updateReq.Ad,
+		Soyad:       updateReq.Soyad,
+		Telefon:     updateReq.Telefon,
+		DogumTarihi: updateReq.DogumTarihi,
+	})
+	if err == errNotFound {
 		http.Error(w, `{"error": "Kullanıcı bulunamadı"}`, http.StatusNotFound)
 		return
+	} else if err != nil {
+		http.Error(w, `{"error": "Profil güncellenirken hata oluştu"}`, http.StatusInternalServerError)
+		return
 	}
 
 	// Güncellenmiş kullanıcı bilgilerini döndür
-	updatedUser, err := getUserByEmail(email)
+	updatedUser, err := s.getUserByEmail(email)
 	if err != nil {
 		http.Error(w, `{"error": "Güncellenmiş bilgiler alınamadı"}`, http.StatusInternalServerError)
 		return
@@ -147,6 +147,11 @@ func updateUserProfileHandler(w http.ResponseWriter, r *http.Request) {
 		Telefon:     updatedUser.Telefon,
 		DogumTarihi: updatedUser.DogumTarihi,
 		Provider:    updatedUser.Provider,
+		Identities:  updatedUser.Identities,
+		HasPassword: updatedUser.Sifre != "",
+		TwoFactor:   updatedUser.TOTPEnabled,
+		Roles:       userRoles(updatedUser),
+		Permissions: effectivePermissions(updatedUser),
 		CreatedAt:   updatedUser.CreatedAt,
 	}
 
@@ -155,32 +160,139 @@ func updateUserProfileHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // validateTokenAndGetEmail, JWT token'ını doğrular ve email'i döndürür
-func validateTokenAndGetEmail(tokenString string) (string, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
-	})
-
+func (s *Server) validateTokenAndGetEmail(tokenString string) (string, error) {
+	claims, err := s.parseToken(tokenString)
+	if err != nil {
+		return "", err
+	}
+	// Token verildikten sonra e-posta değişmiş olabilir; güncel adres kullanıcı ID'sinden okunur
+	user, err := s.getUserByClaims(claims)
 	if err != nil {
 		return "", err
 	}
+	if err := checkUserActive(user); err != nil {
+		return "", err
+	}
+	return user.Email, nil
+}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims.Email, nil
+// authenticateRequest, Authorization başlığındaki Bearer token'ı doğrular.
+// Hata durumunda yanıtı kendisi yazar ve false döndürür.
+func (s *Server) authenticateRequest(w http.ResponseWriter, r *http.Request) (*Claims, bool) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		http.Error(w, `{"error": "Authorization header gerekli"}`, http.StatusUnauthorized)
+		return nil, false
+	}
+
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if tokenString == authHeader {
+		http.Error(w, `{"error": "Bearer token formatı gerekli"}`, http.StatusUnauthorized)
+		return nil, false
 	}
 
-	return "", jwt.ErrSignatureInvalid
+	claims, err := s.parseToken(tokenString)
+	if err != nil {
+		http.Error(w, `{"error": "Geçersiz token"}`, http.StatusUnauthorized)
+		return nil, false
+	}
+	return claims, true
 }
 
 // getUserByEmail, email'e göre kullanıcıyı veritabanından getirir
-func getUserByEmail(email string) (*User, error) {
+func (s *Server) getUserByEmail(email string) (*User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	var user User
-	err := usersCollection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	return s.users.FindByEmail(ctx, email)
+}
+
+// getUserByClaims, token'ın sahibini kullanıcı ID'siyle (sub) getirir. E-posta adresi
+// değiştirilebildiği için token'daki e-posta yerine bu kullanılmalıdır.
+func (s *Server) getUserByClaims(claims *Claims) (*User, error) {
+	userID, err := primitive.ObjectIDFromHex(claims.Subject)
 	if err != nil {
 		return nil, err
 	}
 
-	return &user, nil
-}
\ No newline at end of file
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return s.users.FindByID(ctx, userID)
+}
+
+// changePasswordHandler, mevcut şifreyi doğrulayarak yeni şifre belirler. İsteği yapan cihaz
+// dışındaki tüm oturumlar kapatılır ve kullanıcıya bilgilendirme e-postası gönderilir.
+func (s *Server) changePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims, ok := s.authenticateRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Geçersiz JSON formatı"}`, http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.getUserByClaims(claims)
+	if err != nil {
+		http.Error(w, `{"error": "Kullanıcı bulunamadı"}`, http.StatusNotFound)
+		return
+	}
+	if user.Sifre == "" {
+		// Yalnızca sosyal girişle kullanılan hesaplar şifreyi "şifremi unuttum" akışıyla belirler
+		http.Error(w, `{"error": "Hesabınızda şifre yok, şifre belirlemek için şifremi unuttum adımını kullanın"}`, http.StatusBadRequest)
+		return
+	}
+	if !checkPasswordHash(req.CurrentPassword, user.Sifre) {
+		http.Error(w, `{"error": "Mevcut şifre hatalı"}`, http.StatusUnauthorized)
+		return
+	}
+
+	errs := passwordPolicy.Validate("newPassword", req.NewPassword, PasswordContext{Email: user.Email, Ad: user.Ad, Soyad: user.Soyad})
+	if req.NewPassword == req.CurrentPassword {
+		errs = append(errs, FieldError{Field: "newPassword", Code: "same_as_current", Message: "Yeni şifre mevcut şifreyle aynı olamaz"})
+	}
+	if len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	hashedPassword, err := hashPassword(req.NewPassword)
+	if err != nil {
+		log.Printf("Şifre şifreleme hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Eşzamanlı iki değişiklikten yalnızca biri, okunan şifre hâlâ geçerliyken uygulanır
+	err = s.users.ChangePassword(ctx, user.ID, user.Sifre, hashedPassword)
+	if err == errConflict {
+		http.Error(w, `{"error": "Şifre bu sırada değişti, lütfen tekrar deneyin"}`, http.StatusConflict)
+		return
+	} else if err != nil {
+		log.Printf("Şifre güncelleme hatası: %v", err)
+		http.Error(w, `{"error": "Şifre güncellenemedi"}`, http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.revokeOtherUserTokens(ctx, user.ID, claims.SessionID); err != nil {
+		log.Printf("Oturum iptal hatası: %v", err)
+	}
+
+	go func() {
+		body := fmt.Sprintf("Merhaba %s,\n\nHesabınızın şifresi %s tarihinde değiştirildi ve diğer cihazlardaki oturumlarınız kapatıldı.\n\nBu işlemi siz yapmadıysanız lütfen hemen şifremi unuttum adımıyla yeni şifre belirleyin ve bizimle iletişime geçin.", user.Ad, time.Now().Format("02.01.2006 15:04"))
+		if err := s.sendEmail(user.Email, "Şifreniz değiştirildi", body); err != nil {
+			log.Printf("E-posta gönderim hatası (asenkron): %v", err)
+		}
+	}()
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MessageResponse{Message: "Şifreniz değiştirildi"})
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// CodePurpose, doğrulama kodunun hangi işlem için üretildiğini belirtir.
+type CodePurpose string
+
+const (
+	CodePurposeRegister    CodePurpose = "register"
+	CodePurposeReset       CodePurpose = "reset"
+	CodePurposeEmailChange CodePurpose = "email-change"
+	CodePurposeLogin       CodePurpose = "login"
+	CodePurposeDelete      CodePurpose = "delete-account"
+)
+
+const (
+	maxCodeAttempts    = 5                // Bu kadar hatalı denemeden sonra kod yakılır
+	codeResendCooldown = 60 * time.Second // Aynı adrese yeni kod istemek için beklenecek süre
+	minCodeSecretBytes = 32
+)
+
+// verificationCodeSecret, kod özetlerinin HMAC anahtarıdır. Kodlar yalnızca 6 hane olduğu
+// için anahtarsız bir özet, veritabanı sızdığında tüm olasılıklar denenerek çözülebilir.
+var verificationCodeSecret []byte
+
+// InitVerificationSecret, VERIFICATION_CODE_SECRET değişkenini yükler. Anahtar yoksa veya
+// kısaysa sunucu başlatılmaz. Anahtar değiştiğinde o ana kadar gönderilmiş kodlar geçersiz olur.
+func InitVerificationSecret() {
+	secret := os.Getenv("VERIFICATION_CODE_SECRET")
+	if secret == "" {
+		log.Fatal("VERIFICATION_CODE_SECRET ortam değişkeni tanımlı değil.")
+	}
+	if len(secret) < minCodeSecretBytes {
+		log.Fatalf("VERIFICATION_CODE_SECRET en az %d bayt olmalı.", minCodeSecretBytes)
+	}
+	verificationCodeSecret = []byte(secret)
+}
+
+var (
+	errCodeInvalid         = errors.New("doğrulama kodu geçersiz veya süresi dolmuş")
+	errCodeTooManyAttempts = errors.New("çok fazla hatalı deneme")
+)
+
+// CodeCooldownError, yeniden gönderim beklemesi dolmadan yeni kod istendiğinde döner.
+type CodeCooldownError struct {
+	RetryAfter time.Duration
+}
+
+func (e *CodeCooldownError) Error() string {
+	return fmt.Sprintf("yeni kod için %d saniye bekleyin", int(e.RetryAfter.Seconds()))
+}
+
+// writeRetryAfter, 429 yanıtlarına Retry-After başlığını (saniye) ekler.
+func writeRetryAfter(w http.ResponseWriter, d time.Duration) {
+	seconds := int(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+}
+
+// generateVerificationCode, crypto/rand ile 6 haneli bir kod üretir.
+func generateVerificationCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// hashVerificationCode, kodu amacı ve e-posta adresiyle birlikte sunucu anahtarıyla özetler;
+// veritabanında kodun kendisi tutulmaz. Amaç özete dahil olduğu için bir amaçla üretilen kod
+// diğerinde eşleşemez.
+func hashVerificationCode(purpose CodePurpose, email, code string) string {
+	mac := hmac.New(sha256.New, verificationCodeSecret)
+	mac.Write([]byte(string(purpose) + ":" + email + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// issueVerificationCode, e-posta adresi ve amaç için yeni bir kod üretir ve özetini kaydeder.
+// Aynı amaçla üretilmiş önceki kod varsa geçersiz olur ve deneme sayacı sıfırlanır; diğer
+// amaçlarla üretilmiş kodlar etkilenmez.
+func (s *Server) issueVerificationCode(ctx context.Context, purpose CodePurpose, email string, ttl time.Duration) (string, error) {
+	code, err := generateVerificationCode()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = s.codes.Issue(ctx, VerificationCode{
+		Email:      email,
+		Purpose:    purpose,
+		CodeHash:   hashVerificationCode(purpose, email, code),
+		ExpiresAt:  now.Add(ttl),
+		LastSentAt: now,
+	}, codeResendCooldown)
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// consumeVerificationCode, kodu doğrular ve doğruysa aynı işlemde siler. Doğru kodla gelen
+// eşzamanlı isteklerden yalnızca biri kaydı silebildiği için kod ikinci kez kullanılamaz.
+// Her hatalı denemede sayaç artar ve maxCodeAttempts'e ulaşıldığında kod silinir.
+func (s *Server) consumeVerificationCode(ctx context.Context, purpose CodePurpose, email, code string) error {
+	return s.codes.Consume(ctx, purpose, email, hashVerificationCode(purpose, email, code), maxCodeAttempts, time.Now())
+}
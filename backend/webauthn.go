@@ -0,0 +1,408 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const webauthnSessionTTL = 5 * time.Minute
+
+var errWebAuthnSessionInvalid = errors.New("passkey oturumu geçersiz veya süresi dolmuş")
+
+// webAuthn, WEBAUTHN_RP_ID tanımlı değilse nil kalır ve passkey endpoint'leri 503 döner.
+var webAuthn *webauthn.WebAuthn
+
+// InitWebAuthn, relying party ayarlarını ortam değişkenlerinden okur:
+// WEBAUTHN_RP_ID (ör. eventra.app), WEBAUTHN_RP_ORIGINS (virgülle ayrılmış; Android için
+// "android:apk-key-hash:..." kökenleri de eklenebilir) ve isteğe bağlı WEBAUTHN_RP_NAME.
+func InitWebAuthn() {
+	rpID := os.Getenv("WEBAUTHN_RP_ID")
+	if rpID == "" {
+		log.Println("WEBAUTHN_RP_ID tanımlı değil, passkey girişi kapalı.")
+		return
+	}
+
+	var origins []string
+	for _, origin := range strings.Split(os.Getenv("WEBAUTHN_RP_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	if len(origins) == 0 {
+		origins = []string{"https://" + rpID}
+	}
+
+	displayName := os.Getenv("WEBAUTHN_RP_NAME")
+	if displayName == "" {
+		displayName = totpIssuer
+	}
+
+	var err error
+	webAuthn, err = webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: displayName,
+		RPOrigins:     origins,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementRequired,
+			UserVerification: protocol.VerificationRequired,
+		},
+	})
+	if err != nil {
+		log.Fatal("WebAuthn yapılandırma hatası:", err)
+	}
+}
+
+// webauthnUser, User'ı kütüphanenin beklediği arayüze uyarlar. Kullanıcı tanıtıcısı
+// olarak ObjectID kullanılır; kişisel bilgi içermez ve kullanıcıya göre sabittir.
+type webauthnUser struct {
+	user        *User
+	credentials []webauthn.Credential
+}
+
+func (u *webauthnUser) WebAuthnID() []byte   { return u.user.ID[:] }
+func (u *webauthnUser) WebAuthnName() string { return u.user.Email }
+func (u *webauthnUser) WebAuthnDisplayName() string {
+	return strings.TrimSpace(u.user.Ad + " " + u.user.Soyad)
+}
+func (u *webauthnUser) WebAuthnCredentials() []webauthn.Credential { return u.credentials }
+
+// loadWebAuthnUser, kullanıcının kayıtlı tüm passkey'leriyle birlikte adaptörü oluşturur.
+func loadWebAuthnUser(ctx context.Context, user *User) (*webauthnUser, error) {
+	cursor, err := webauthnCredentialsCollection.Find(ctx, bson.M{"userId": user.ID})
+	if err != nil {
+		return nil, err
+	}
+	var stored []WebAuthnCredential
+	if err := cursor.All(ctx, &stored); err != nil {
+		return nil, err
+	}
+
+	wu := &webauthnUser{user: user}
+	for _, c := range stored {
+		wu.credentials = append(wu.credentials, c.Credential)
+	}
+	return wu, nil
+}
+
+// saveWebAuthnSession, seremoni verisini saklar ve istemciye verilecek oturum kimliğini döndürür.
+func saveWebAuthnSession(ctx context.Context, ceremony string, userID primitive.ObjectID, data *webauthn.SessionData) (string, error) {
+	sessionID, err := generateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	_, err = webauthnSessionsCollection.InsertOne(ctx, WebAuthnSession{
+		TokenHash: hashToken(sessionID),
+		Ceremony:  ceremony,
+		UserID:    userID,
+		Data:      *data,
+		ExpiresAt: time.Now().Add(webauthnSessionTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return sessionID, nil
+}
+
+// consumeWebAuthnSession, oturumu tek kullanımlık olarak okur ve siler.
+func consumeWebAuthnSession(ctx context.Context, sessionID, ceremony string) (*WebAuthnSession, error) {
+	if sessionID == "" {
+		return nil, errWebAuthnSessionInvalid
+	}
+	var session WebAuthnSession
+	err := webauthnSessionsCollection.FindOneAndDelete(ctx, bson.M{
+		"tokenHash": hashToken(sessionID),
+		"ceremony":  ceremony,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return nil, errWebAuthnSessionInvalid
+	} else if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// webauthnAvailable, passkey desteği yapılandırılmamışsa isteği 503 ile sonlandırır.
+func webauthnAvailable(w http.ResponseWriter) bool {
+	if webAuthn == nil {
+		http.Error(w, `{"error": "Passkey girişi yapılandırılmamış"}`, http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// beginWebAuthnRegistrationHandler, oturum açmış kullanıcı için passkey oluşturma seçeneklerini döndürür.
+func (s *Server) beginWebAuthnRegistrationHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !webauthnAvailable(w) {
+		return
+	}
+	claims, ok := s.authenticateRequest(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := s.getUserByClaims(claims)
+	if err != nil {
+		http.Error(w, `{"error": "Kullanıcı bulunamadı"}`, http.StatusNotFound)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	wu, err := loadWebAuthnUser(ctx, user)
+	if err != nil {
+		log.Printf("Passkey okuma hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+
+	// Aynı cihazın ikinci kez kaydedilmesini engellemek için mevcut passkey'ler hariç tutulur
+	creation, data, err := webAuthn.BeginRegistration(wu,
+		webauthn.WithExclusions(webauthn.Credentials(wu.credentials).CredentialDescriptors()),
+	)
+	if err != nil {
+		log.Printf("Passkey kayıt başlatma hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+
+	sessionID, err := saveWebAuthnSession(ctx, "register", user.ID, data)
+	if err != nil {
+		log.Printf("Passkey oturumu kaydetme hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sessionId": sessionID,
+		"options":   creation,
+	})
+}
+
+// finishWebAuthnRegistrationHandler, cihazın döndürdüğü attestation'ı doğrular ve passkey'i kaydeder.
+func (s *Server) finishWebAuthnRegistrationHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !webauthnAvailable(w) {
+		return
+	}
+	claims, ok := s.authenticateRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req WebAuthnFinishRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Geçersiz JSON formatı"}`, http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.getUserByClaims(claims)
+	if err != nil {
+		http.Error(w, `{"error": "Kullanıcı bulunamadı"}`, http.StatusNotFound)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	session, err := consumeWebAuthnSession(ctx, req.SessionID, "register")
+	if err != nil || session.UserID != user.ID {
+		http.Error(w, `{"error": "Passkey oturumu geçersiz veya süresi dolmuş"}`, http.StatusBadRequest)
+		return
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(req.Credential)
+	if err != nil {
+		http.Error(w, `{"error": "Geçersiz passkey yanıtı"}`, http.StatusBadRequest)
+		return
+	}
+
+	wu, err := loadWebAuthnUser(ctx, user)
+	if err != nil {
+		log.Printf("Passkey okuma hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+
+	credential, err := webAuthn.CreateCredential(wu, session.Data, parsed)
+	if err != nil {
+		log.Printf("Passkey doğrulama hatası: %v", err)
+		http.Error(w, `{"error": "Passkey doğrulanamadı"}`, http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = "Passkey"
+	}
+	stored := WebAuthnCredential{
+		UserID:       user.ID,
+		CredentialID: credential.ID,
+		Name:         name,
+		Credential:   *credential,
+		CreatedAt:    time.Now(),
+	}
+	result, err := webauthnCredentialsCollection.InsertOne(ctx, stored)
+	if err != nil {
+		log.Printf("Passkey kaydetme hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+	stored.ID = result.InsertedID.(primitive.ObjectID)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(stored)
+}
+
+// beginWebAuthnLoginHandler, kullanıcı adı sormadan (discoverable) passkey girişi başlatır.
+// E-posta istenmediği için bu adım hangi hesapların var olduğunu da ele vermez.
+func beginWebAuthnLoginHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !webauthnAvailable(w) {
+		return
+	}
+
+	assertion, data, err := webAuthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		log.Printf("Passkey giriş başlatma hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sessionID, err := saveWebAuthnSession(ctx, "login", primitive.NilObjectID, data)
+	if err != nil {
+		log.Printf("Passkey oturumu kaydetme hatası: %v", err)
+		http.Error(w, `{"error": "Sunucu hatası"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sessionId": sessionID,
+		"options":   assertion,
+	})
+}
+
+// finishWebAuthnLoginHandler, imzalı assertion'ı doğrular ve şifreli girişle aynı token ikilisini verir.
+func (s *Server) finishWebAuthnLoginHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !webauthnAvailable(w) {
+		return
+	}
+
+	var req WebAuthnFinishRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Geçersiz JSON formatı"}`, http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	session, err := consumeWebAuthnSession(ctx, req.SessionID, "login")
+	if err != nil {
+		s.recordLoginEvent(r, nil, "", "passkey", false, "invalid_session")
+		http.Error(w, `{"error": "Passkey oturumu geçersiz veya süresi dolmuş"}`, http.StatusBadRequest)
+		return
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(req.Credential)
+	if err != nil {
+		s.recordLoginEvent(r, nil, "", "passkey", false, "invalid_response")
+		http.Error(w, `{"error": "Geçersiz passkey yanıtı"}`, http.StatusBadRequest)
+		return
+	}
+
+	// Kütüphane, cihazın gönderdiği credential ID ve user handle ile sahibini sorar
+	var owner *User
+	findUser := func(rawID, userHandle []byte) (webauthn.User, error) {
+		var stored WebAuthnCredential
+		if err := webauthnCredentialsCollection.FindOne(ctx, bson.M{"credentialId": rawID}).Decode(&stored); err != nil {
+			return nil, err
+		}
+		if string(stored.UserID[:]) != string(userHandle) {
+			return nil, errors.New("user handle passkey sahibiyle eşleşmiyor")
+		}
+		user, err := s.users.FindByID(ctx, stored.UserID)
+		if err != nil {
+			return nil, err
+		}
+		owner = user
+		return loadWebAuthnUser(ctx, user)
+	}
+
+	_, credential, err := webAuthn.ValidatePasskeyLogin(findUser, session.Data, parsed)
+	if err != nil {
+		// Sahip bulunduysa kayıt hesaba bağlanır; bilinmeyen passkey'lerde owner nil kalır
+		s.recordLoginEvent(r, owner, "", "passkey", false, "invalid_assertion")
+		http.Error(w, `{"error": "Passkey doğrulanamadı"}`, http.StatusUnauthorized)
+		return
+	}
+	if credential.Authenticator.CloneWarning {
+		// Sayaç geriye gitti: anahtar kopyalanmış olabilir
+		log.Printf("Passkey klon uyarısı: kullanıcı %s", owner.ID.Hex())
+		s.recordLoginEvent(r, owner, owner.Email, "passkey", false, "clone_warning")
+		http.Error(w, `{"error": "Passkey doğrulanamadı"}`, http.StatusUnauthorized)
+		return
+	}
+
+	now := time.Now()
+	_, err = webauthnCredentialsCollection.UpdateOne(ctx,
+		bson.M{"credentialId": credential.ID, "userId": owner.ID},
+		bson.M{"$set": bson.M{"credential": credential, "lastUsedAt": now}},
+	)
+	if err != nil {
+		log.Printf("Passkey güncelleme hatası: %v", err)
+	}
+
+	switch err := checkUserActive(owner); err {
+	case errAccountDeleted:
+		s.recordLoginEvent(r, owner, owner.Email, "passkey", false, inactiveReason(err))
+		http.Error(w, `{"error": "Bu hesap silinme sürecinde"}`, http.StatusForbidden)
+		return
+	case errAccountBanned:
+		s.recordLoginEvent(r, owner, owner.Email, "passkey", false, inactiveReason(err))
+		http.Error(w, `{"error": "Hesabınız askıya alınmış"}`, http.StatusForbidden)
+		return
+	}
+
+	pair, err := s.issueLoginTokens(ctx, owner, r, "passkey")
+	if err != nil {
+		log.Printf("Token oluşturma hatası: %v", err)
+		http.Error(w, `{"error": "Token oluşturulamadı"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(TokenResponse{
+		Status:       "success",
+		Message:      "Giriş başarılı",
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
+	})
+}